@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// budget limits how many bytes of chunk data the master holds at once. The
+// reader acquires the capacity of a chunk's buffer, which is what the chunk
+// keeps in memory, before submitting it and the scheduler releases it when
+// the chunk is done or failed, so reading blocks while the workers are
+// saturated instead of buffering the whole file.
+type budget struct {
+	mu    sync.Mutex
+	limit int64
+	used  int64
+	// freed is closed and replaced every time bytes are released
+	freed chan struct{}
+}
+
+func newBudget(limit int64) *budget {
+	return &budget{limit: limit, freed: make(chan struct{})}
+}
+
+// acquire blocks until n bytes fit in the budget. A request larger than the
+// whole budget is let through once nothing else is held, so a single large
+// chunk cannot deadlock the reader.
+func (b *budget) acquire(ctx context.Context, n int64) error {
+	for {
+		b.mu.Lock()
+		if b.used == 0 || b.used+n <= b.limit {
+			b.used += n
+			b.mu.Unlock()
+			return nil
+		}
+		freed := b.freed
+		b.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-freed:
+		}
+	}
+}
+
+// release returns n bytes to the budget
+func (b *budget) release(n int64) {
+	b.mu.Lock()
+	b.used -= n
+	close(b.freed)
+	b.freed = make(chan struct{})
+	b.mu.Unlock()
+}
+
+// inUse returns the number of bytes held
+func (b *budget) inUse() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
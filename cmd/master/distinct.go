@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/hll"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/query"
+)
+
+// distinctCounts merges the HyperLogLog sketches the workers return for each
+// group. Merging is idempotent, so a chunk that is retried after its sketches
+// were added does not skew the estimates.
+type distinctCounts struct {
+	mu        sync.Mutex
+	field     string
+	precision int
+	sketches  map[string]*hll.Sketch
+}
+
+func newDistinctCounts(field string, precision int) *distinctCounts {
+	return &distinctCounts{
+		field:     field,
+		precision: precision,
+		sketches:  make(map[string]*hll.Sketch),
+	}
+}
+
+// add merges the sketches of one chunk
+func (d *distinctCounts) add(sketches []*pb.DistinctSketch) error {
+	loaded := make(map[string]*hll.Sketch, len(sketches))
+	for _, ds := range sketches {
+		if int(ds.Precision) != d.precision {
+			return fmt.Errorf("distinct sketch for key %q has precision %d, want %d", ds.Key, ds.Precision, d.precision)
+		}
+		s, err := hll.Load(int(ds.Precision), ds.Registers, ds.Sparse)
+		if err != nil {
+			return fmt.Errorf("distinct sketch for key %q: %w", ds.Key, err)
+		}
+		loaded[ds.Key] = s
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for k, s := range loaded {
+		if mine, ok := d.sketches[k]; ok {
+			mine.Merge(s)
+		} else {
+			d.sketches[k] = s
+		}
+	}
+	return nil
+}
+
+// estimates returns the estimated number of distinct values of each key, or
+// of every group when keys is nil
+func (d *distinctCounts) estimates(keys []string) map[string]uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if keys == nil {
+		for k := range d.sketches {
+			keys = append(keys, k)
+		}
+	}
+	estimates := make(map[string]uint64, len(keys))
+	for _, k := range keys {
+		if s, ok := d.sketches[k]; ok {
+			estimates[k] = s.Estimate()
+		}
+	}
+	return estimates
+}
+
+// logDistinct prints the distinct estimates of the given keys, or of every
+// group when keys is nil
+func logDistinct(groupBy query.GroupBy, d *distinctCounts, keys []string) {
+	log.Printf("[MASTER] Distinct %s per %s (HyperLogLog, standard error %.1f%%): %v",
+		d.field, groupBy, 100*hll.StdError(d.precision), d.estimates(keys))
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// stdinPath is the -file name of standard input
+const stdinPath = "-"
+
+// fileList is a flag that can be repeated to name several inputs
+type fileList []string
+
+func (l *fileList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *fileList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// patternList is a flag holding glob patterns separated by commas
+type patternList []string
+
+func (l *patternList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *patternList) Set(value string) error {
+	for _, p := range strings.Split(value, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if _, err := filepath.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+		*l = append(*l, p)
+	}
+	return nil
+}
+
+// matchAny reports whether the base name of path matches one of patterns
+func matchAny(patterns []string, path string) bool {
+	name := filepath.Base(path)
+	for _, p := range patterns {
+		// the patterns were checked by patternList.Set
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// inputFiles expands the inputs of a job into the files to read, in order.
+// An input is a file, a glob pattern or a directory, whose files are listed
+// in name order and, with recursive, those of its subdirectories too. Files
+// found through a pattern or a directory are kept if their name matches one
+// of include, or include is empty, and none of exclude; files named
+// explicitly are always kept. A file is read once however often it is named.
+// stdinPath stands for standard input, and named pipes are read like files.
+func inputFiles(inputs []string, recursive bool, include, exclude []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(path string, explicit bool) {
+		path = filepath.Clean(path)
+		// the same file can be named by a relative and an absolute path
+		key := path
+		if path != stdinPath {
+			if abs, err := filepath.Abs(path); err == nil {
+				key = abs
+			}
+		}
+		if seen[key] {
+			return
+		}
+		if !explicit && ((len(include) > 0 && !matchAny(include, path)) || matchAny(exclude, path)) {
+			return
+		}
+		seen[key] = true
+		files = append(files, path)
+	}
+	for _, input := range inputs {
+		if input == stdinPath {
+			add(input, true)
+			continue
+		}
+		paths := []string{input}
+		explicit := true
+		if strings.ContainsAny(input, "*?[") {
+			var err error
+			if paths, err = filepath.Glob(input); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", input, err)
+			}
+			if len(paths) == 0 {
+				return nil, fmt.Errorf("no files match %q", input)
+			}
+			explicit = false
+		}
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				add(path, explicit)
+				continue
+			}
+			found, err := dirFiles(path, recursive)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range found {
+				add(f, false)
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files to read in %s", strings.Join(inputs, ", "))
+	}
+	return files, nil
+}
+
+// dirFiles lists the regular files of a directory in name order, descending
+// into subdirectories when recursive is set. Hidden files and directories,
+// whose names start with a dot, are left out.
+func dirFiles(dir string, recursive bool) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		hidden := path != dir && strings.HasPrefix(d.Name(), ".")
+		if d.IsDir() {
+			if path != dir && (hidden || !recursive) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if hidden {
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			// follow links to files but not to directories
+			info, err := os.Stat(path)
+			if err != nil || !info.Mode().IsRegular() {
+				return nil
+			}
+		} else if !d.Type().IsRegular() {
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	})
+	return files, err
+}
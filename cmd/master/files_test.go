@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestInputFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.log", "b.log", "c.txt", ".hidden", "sub/d.log", "empty/.keep"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	relative, err := filepath.Rel(cwd, filepath.Join(dir, "a.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := func(names ...string) []string {
+		paths := make([]string, len(names))
+		for i, name := range names {
+			paths[i] = filepath.Join(dir, name)
+		}
+		return paths
+	}
+	for _, c := range []struct {
+		name             string
+		inputs           []string
+		recursive        bool
+		include, exclude []string
+		// want is nil when an error is expected
+		want []string
+	}{
+		{name: "no matches", inputs: in("*.gz")},
+		{name: "empty directory", inputs: in("empty")},
+		{name: "missing file", inputs: in("missing.log")},
+		{name: "directory", inputs: in(""), want: in("a.log", "b.log", "c.txt")},
+		{name: "recursive", inputs: in(""), recursive: true, want: in("a.log", "b.log", "c.txt", "sub/d.log")},
+		{name: "include", inputs: in(""), include: []string{"*.log"}, want: in("a.log", "b.log")},
+		{name: "exclude overrides include", inputs: in(""), include: []string{"*.log"}, exclude: []string{"b*"}, want: in("a.log")},
+		{name: "pattern", inputs: in("*.log"), exclude: []string{"a*"}, want: in("b.log")},
+		// files named explicitly are kept whatever the filters
+		{name: "explicit", inputs: in("c.txt"), include: []string{"*.log"}, exclude: []string{"c*"}, want: in("c.txt")},
+		{name: "named twice", inputs: []string{filepath.Join(dir, "a.log"), relative, dir + "/./a.log"}, want: in("a.log")},
+		{name: "named and matched", inputs: in("b.log", "*.log", ""), want: in("b.log", "a.log", "c.txt")},
+		{name: "stdin", inputs: []string{stdinPath, stdinPath}, want: []string{stdinPath}},
+	} {
+		got, err := inputFiles(c.inputs, c.recursive, c.include, c.exclude)
+		if c.want == nil {
+			if err == nil {
+				t.Errorf("%s: got %v, want an error", c.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		for i := range got {
+			if abs, err := filepath.Abs(got[i]); err == nil && got[i] != stdinPath {
+				got[i] = abs
+			}
+		}
+		if !slices.Equal(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
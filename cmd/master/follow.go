@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"time"
+
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/compressed"
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/query"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/topk"
+)
+
+// headSize is how many bytes at the start of a followed file are checked
+// for changes
+const headSize = 1024
+
+// follower tails a growing log file like tail -F. It notices when the file
+// is rotated, because the path now names another file, and when it is
+// truncated, because it became shorter than what was read or its first bytes
+// changed, as happens when it is truncated and written past the old end
+// between two polls.
+type follower struct {
+	path string
+	file *os.File
+	info os.FileInfo
+	// offset is where the next read starts in file
+	offset int64
+	// partial is an incomplete last line waiting for its newline
+	partial []byte
+	// headSum is the checksum of the first headLen bytes read
+	headSum uint32
+	headLen int64
+}
+
+// openFollower opens path for following, from its current end unless
+// fromStart is set
+func openFollower(path string, fromStart bool) (*follower, error) {
+	f := &follower{path: path}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 4)
+	if n, _ := f.file.ReadAt(header, 0); compressed.Detect(header[:n]) != compressed.None {
+		f.file.Close()
+		return nil, errors.New("compressed files cannot be followed")
+	}
+	if !fromStart {
+		f.offset = f.info.Size()
+		if err := f.updateHead(); err != nil {
+			f.file.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (f *follower) open() error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.info, f.offset, f.partial = file, info, 0, nil
+	f.headSum, f.headLen = 0, 0
+	return nil
+}
+
+func (f *follower) close() {
+	f.file.Close()
+}
+
+// poll returns up to max bytes of complete lines written since the last
+// call, and whether the file started over because it was rotated or
+// truncated, in which case the lines come from the new file. A file that was
+// rotated is read to its end before the new one is opened.
+func (f *follower) poll(max int) ([]byte, bool, error) {
+	// check for truncation first, as the file may have grown past offset
+	// again since
+	truncated, err := f.truncated()
+	if err != nil {
+		return nil, false, err
+	}
+	if truncated {
+		f.offset, f.partial = 0, nil
+		f.headSum, f.headLen = 0, 0
+		log.Printf("[MASTER] %s was truncated, reading it from the start", f.path)
+		data, err := f.read(max)
+		return data, true, err
+	}
+	data, err := f.read(max)
+	if err != nil || len(data) > 0 {
+		return data, false, err
+	}
+	// nothing new: check whether the file was replaced
+	info, err := os.Stat(f.path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// rotated away and not created again yet
+		return nil, false, nil
+	case err != nil:
+		return nil, false, err
+	case !os.SameFile(info, f.info):
+		f.file.Close()
+		if err := f.open(); err != nil {
+			return nil, false, err
+		}
+		log.Printf("[MASTER] %s was rotated, following the new file", f.path)
+	default:
+		return nil, false, nil
+	}
+	data, err = f.read(max)
+	return data, true, err
+}
+
+// truncated reports whether the file was truncated since the last read: it
+// is shorter than offset, or its first bytes are not the ones read
+func (f *follower) truncated() (bool, error) {
+	info, err := f.file.Stat()
+	if err != nil {
+		return false, err
+	}
+	if info.Size() < f.offset {
+		return true, nil
+	}
+	if f.headLen == 0 {
+		return false, nil
+	}
+	head := make([]byte, f.headLen)
+	if _, err := f.file.ReadAt(head, 0); err != nil {
+		return false, err
+	}
+	return crc32.ChecksumIEEE(head) != f.headSum, nil
+}
+
+// updateHead records the checksum of the first headSize bytes read, until
+// that many were read
+func (f *follower) updateHead() error {
+	n := min(f.offset, headSize)
+	if n == f.headLen {
+		return nil
+	}
+	head := make([]byte, n)
+	if _, err := f.file.ReadAt(head, 0); err != nil {
+		return err
+	}
+	f.headSum, f.headLen = crc32.ChecksumIEEE(head), n
+	return nil
+}
+
+// read returns up to max bytes of complete lines from offset. A line longer
+// than max is returned in pieces.
+func (f *follower) read(max int) ([]byte, error) {
+	buf := make([]byte, max-len(f.partial))
+	n, err := f.file.ReadAt(buf, f.offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	f.offset += int64(n)
+	if err := f.updateHead(); err != nil {
+		return nil, err
+	}
+	data := append(f.partial, buf[:n]...)
+	f.partial = nil
+	last := bytes.LastIndexByte(data, '\n')
+	if last < 0 && len(data) < max {
+		// keep waiting for the end of the line
+		f.partial = data
+		return nil, nil
+	}
+	if last >= 0 && last < len(data)-1 {
+		f.partial = append([]byte(nil), data[last+1:]...)
+		data = data[:last+1]
+	}
+	return data, nil
+}
+
+// followOptions are the settings of follow mode
+type followOptions struct {
+	fromStart bool
+	// interval is how often the file is checked for new lines and
+	// reportEvery how often the window is reported
+	interval    time.Duration
+	reportEvery time.Duration
+}
+
+// follow tails path and submits every batch of new lines to the job until
+// stop is closed, calling report every reportEvery. Batches are at most
+// chunkSize bytes; a burst of lines is sent as several batches at once. A
+// last line still waiting for its newline is sent when following stops.
+func follow(ctx context.Context, stop <-chan struct{}, j *job, path string, opts followOptions, report func()) error {
+	f, err := openFollower(path, opts.fromStart)
+	if err != nil {
+		return err
+	}
+	defer f.close()
+	j.startFile(path)
+	log.Printf("[MASTER] Following %s from offset %d, checking every %v", path, f.offset, opts.interval)
+	poll := time.NewTicker(opts.interval)
+	defer poll.Stop()
+	reports := time.NewTicker(opts.reportEvery)
+	defer reports.Stop()
+	flush := func() error {
+		if len(f.partial) == 0 {
+			return nil
+		}
+		data, offset := f.partial, f.offset-int64(len(f.partial))
+		f.partial = nil
+		if err := j.resolve(data); err != nil {
+			return err
+		}
+		return j.submit(ctx, &pb.MapRequest{LogData: data, Offset: offset})
+	}
+	for {
+		// send everything that is ready before waiting again
+		for {
+			select {
+			case <-stop:
+				return flush()
+			default:
+			}
+			offset := f.offset - int64(len(f.partial))
+			data, restarted, err := f.poll(chunkSize)
+			if err != nil {
+				return fmt.Errorf("follow %s: %w", path, err)
+			}
+			if restarted {
+				// the new file may be in another format or have another header
+				j.startFile(path)
+				offset = 0
+			}
+			if len(data) == 0 {
+				break
+			}
+			if err := j.resolve(data); err != nil {
+				return err
+			}
+			if err := j.submit(ctx, &pb.MapRequest{LogData: data, Offset: offset}); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-stop:
+			return flush()
+		case <-poll.C:
+		case <-reports.C:
+			report()
+		}
+	}
+}
+
+// logWindow prints the counts of the window, largest first, and returns the
+// ones reported: the top n when n is set, in time order with a bucket
+func logWindow(groupBy query.GroupBy, bucket *query.Bucket, n int, span string, counters []topk.Counter) []topk.Counter {
+	switch {
+	case n > 0:
+		counters = counters[:min(n, len(counters))]
+		logTop(groupBy, span, counters, 0)
+	case bucket != nil:
+		results := make([]*pb.AggregatedResult, len(counters))
+		for i, c := range counters {
+			results[i] = &pb.AggregatedResult{Key: c.Key, TotalCount: c.Count}
+		}
+		logSeries(groupBy, bucket, results)
+		sortSeries(counters)
+	default:
+		counts := make(map[string]int64, len(counters))
+		for _, c := range counters {
+			counts[c.Key] = c.Count
+		}
+		log.Printf("[MASTER] Results (%s): %v", span, counts)
+	}
+	return counters
+}
+
+// replaceReport writes the report to path through a temporary file, so
+// readers of path always see a complete report
+func replaceReport(r *report, path, format string) error {
+	if path == "-" {
+		return writeReport(r, path, format)
+	}
+	tmp := path + ".tmp"
+	if err := writeReport(r, tmp, format); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
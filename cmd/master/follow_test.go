@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// pollAll polls f and fails unless it returns want and whether the file
+// started over
+func pollAll(t *testing.T, f *follower, want string, restarted bool) {
+	t.Helper()
+	data, got, err := f.poll(chunkSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want || got != restarted {
+		t.Errorf("poll returned %q, restarted %v, want %q, restarted %v", data, got, want, restarted)
+	}
+}
+
+func writeFile(t *testing.T, path, data string, flag int) {
+	t.Helper()
+	file, err := os.OpenFile(path, flag|os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFollowerTruncate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	writeFile(t, path, "one\ntwo\n", os.O_TRUNC)
+	f, err := openFollower(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.close()
+	pollAll(t, f, "one\ntwo\n", false)
+	writeFile(t, path, "three\n", os.O_APPEND)
+	pollAll(t, f, "three\n", false)
+
+	// truncated to fewer bytes than were read
+	writeFile(t, path, "four\n", os.O_TRUNC)
+	pollAll(t, f, "four\n", true)
+
+	// truncated and written past the old end between two polls, as with
+	// copytruncate
+	writeFile(t, path, "five\nsix\nseven\n", os.O_TRUNC)
+	pollAll(t, f, "five\nsix\nseven\n", true)
+	pollAll(t, f, "", false)
+}
+
+func TestFollowerRotate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	writeFile(t, path, "one\n", os.O_TRUNC)
+	f, err := openFollower(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.close()
+	pollAll(t, f, "", false)
+	writeFile(t, path, "two\n", os.O_APPEND)
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	// written to the old file after the rotation
+	writeFile(t, path+".1", "three\n", os.O_APPEND)
+	pollAll(t, f, "two\nthree\n", false)
+	// rotated away and not created again yet
+	pollAll(t, f, "", false)
+	writeFile(t, path, "four\n", os.O_TRUNC)
+	pollAll(t, f, "four\n", true)
+}
+
+func TestFollowSendsPartialLineOnStop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	lines := testLines(2)
+	writeFile(t, path, string(lines)+"10.0.0.9 - - [10/Oct/2000", os.O_TRUNC)
+
+	worker := &recordingMapper{}
+	j := newTestJob(t, worker)
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- follow(context.Background(), stop, j, path, followOptions{
+			fromStart:   true,
+			interval:    time.Millisecond,
+			reportEvery: time.Hour,
+		}, func() {})
+	}()
+	// wait for the complete lines before stopping
+	for deadline := time.Now().Add(10 * time.Second); ; {
+		worker.mu.Lock()
+		n := len(worker.requests)
+		worker.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the complete lines were not sent")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	requests := wait(t, j, worker)
+	if len(requests) != 2 {
+		t.Fatalf("sent %d map requests, want the lines and the partial line", len(requests))
+	}
+	if string(requests[0].LogData) != string(lines) {
+		t.Errorf("first batch %q, want %q", requests[0].LogData, lines)
+	}
+	if last := requests[1]; string(last.LogData) != "10.0.0.9 - - [10/Oct/2000" || last.Offset != int64(len(lines)) {
+		t.Errorf("last batch %q at offset %d, want the partial line at %d", last.LogData, last.Offset, len(lines))
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/compressed"
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+)
+
+// sampleSize is how much of a file is read to detect its format when the
+// workers read the data themselves
+const sampleSize = 64 * 1024
+
+// errNotShareable is returned for inputs that -shared cannot split
+var errNotShareable = errors.New("-shared needs a regular file the workers can read, not a pipe or standard input")
+
+// readInputs submits the chunks of every file to the job, in order. In
+// shared mode the workers read byte ranges of the files themselves, at
+// sharedPath if it is set or else at the absolute path of the file.
+func readInputs(ctx context.Context, j *job, files []string, shared bool, sharedPath string) error {
+	for _, path := range files {
+		name := path
+		if path == stdinPath {
+			name = "standard input"
+		}
+		// Log rather than print so -output - only writes results to stdout
+		log.Printf("Processing log file: %s", name)
+		j.startFile(path)
+		if err := readInput(ctx, j, path, shared, sharedPath); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// readInput submits the chunks of one file, which may be standard input or
+// a named pipe unless shared is set
+func readInput(ctx context.Context, j *job, path string, shared bool, sharedPath string) error {
+	if shared {
+		// Plan byte ranges that the workers read themselves. Check the file
+		// before opening it, as opening a pipe waits for a writer.
+		if path == stdinPath {
+			return errNotShareable
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return errNotShareable
+		}
+		workerPath := sharedPath
+		if workerPath == "" {
+			if workerPath, err = filepath.Abs(path); err != nil {
+				return fmt.Errorf("resolve file path: %w", err)
+			}
+		}
+		return planRanges(ctx, j, path, workerPath)
+	}
+	file := os.Stdin
+	if path != stdinPath {
+		var err error
+		if file, err = os.Open(path); err != nil {
+			return err
+		}
+		defer file.Close()
+	}
+	// Decompress the file if needed, then read it in chunks and send each
+	// chunk to a worker
+	input, compression, err := compressed.Open(file)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+	if compression != compressed.None {
+		log.Printf("[MASTER] Decompressing %s input", compression)
+	}
+	return readChunks(ctx, j, input)
+}
+
+// readChunks reads the input in line-aligned chunks and submits each chunk's
+// data to the job. It only reads forward, so the input may be a pipe; a
+// chunk is submitted as soon as its buffer is full, and the last chunk ends
+// at EOF whether or not the input ends with a newline. Lines are never split
+// across chunks: a line that fills a whole chunk without ending is rejected
+// without being sent.
+func readChunks(ctx context.Context, j *job, file io.Reader) error {
+	// create a buffer to store the chunk data
+	buffer := make([]byte, chunkSize)
+	var leftover []byte
+	// offset is where the next chunk starts in the file
+	var offset int64
+	// skipping is set while the rest of a rejected line is read
+	skipping := false
+	// Read the file in chunks and send each chunk to a worker
+	for {
+		// fill the buffer, as decompressors and pipes return a little data
+		// per read
+		n, err := io.ReadFull(file, buffer)
+		eof := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !eof {
+			return fmt.Errorf("read chunk: %w", err)
+		}
+		// append the leftover data from the previous chunk
+		data := append(leftover, buffer[:n]...)
+		leftover = nil
+		if skipping {
+			newline := bytes.IndexByte(data, '\n')
+			if newline < 0 {
+				offset += int64(len(data))
+				if eof {
+					return nil
+				}
+				continue
+			}
+			data = data[newline+1:]
+			offset += int64(newline) + 1
+			skipping = false
+		}
+		chunk, next := data, int64(len(data))
+		if eof {
+			// flush the tail, dropping only the final newline
+			chunk = bytes.TrimSuffix(data, []byte{'\n'})
+		} else if lastNewline := bytes.LastIndexByte(data, '\n'); lastNewline >= 0 {
+			// split the chunk at the last newline character and keep the
+			// partial line for the next chunk
+			chunk, leftover = data[:lastNewline], data[lastNewline+1:]
+			next = int64(lastNewline) + 1
+		} else if len(data) < chunkSize {
+			// the end of a skipped line left the start of the next one
+			chunk, leftover, next = nil, data, 0
+		} else {
+			// the data is the start of a line longer than a chunk
+			j.rejectLine(offset, data)
+			log.Printf("[MASTER] Rejected the line at offset %d, which is longer than a chunk of %d bytes", offset, chunkSize)
+			offset += next
+			skipping = true
+			continue
+		}
+		if len(chunk) > 0 {
+			// detect the log format from the first chunk
+			if err := j.resolve(chunk); err != nil {
+				return err
+			}
+			// hand the chunk to the scheduler, waiting while the workers are saturated
+			if err := j.submit(ctx, &pb.MapRequest{LogData: chunk, Offset: offset}); err != nil {
+				return err
+			}
+		}
+		offset += next
+		if eof {
+			return nil
+		}
+	}
+}
+
+// planRanges splits a file on shared storage into byte ranges of chunkSize
+// without reading it. Workers open workerPath themselves and align each range
+// to line boundaries: a range skips the partial line it starts in and
+// finishes the line it ends in. Compressed files are split at member
+// boundaries instead, see planCompressedRanges.
+func planRanges(ctx context.Context, j *job, path, workerPath string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	header := make([]byte, 4)
+	n, err := file.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("read header: %w", err)
+	}
+	compression := compressed.Detect(header[:n])
+	// detect the log format from the start of the file
+	input, err := compressed.NewReader(io.NewSectionReader(file, 0, info.Size()), compression)
+	if err != nil {
+		return fmt.Errorf("open %s stream: %w", compression, err)
+	}
+	sample := make([]byte, sampleSize)
+	n, err = io.ReadFull(input, sample)
+	input.Close()
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return fmt.Errorf("read sample: %w", err)
+	}
+	sample = sample[:n]
+	if i := bytes.LastIndexByte(sample, '\n'); i >= 0 && n == sampleSize {
+		sample = sample[:i]
+	}
+	if err := j.resolve(sample); err != nil {
+		return err
+	}
+	size := info.Size()
+	if compression != compressed.None {
+		return planCompressedRanges(ctx, j, file, size, compression, workerPath)
+	}
+	first := j.chunks
+	for offset := int64(0); offset < size; offset += chunkSize {
+		length := min(int64(chunkSize), size-offset)
+		err := j.submit(ctx, &pb.MapRequest{
+			Path:   workerPath,
+			Offset: offset,
+			Length: length,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	log.Printf("[MASTER] Planned %d ranges of %s (%d bytes)", j.chunks-first, workerPath, size)
+	return nil
+}
+
+// planCompressedRanges splits a compressed file on shared storage at member
+// boundaries into ranges of about chunkSize compressed bytes. Workers
+// decompress their range and align it to line boundaries in the decompressed
+// data. Files whose members cannot be found without decompressing them are
+// decompressed by the master instead, which sends their chunks like those of
+// a file the workers cannot read.
+func planCompressedRanges(ctx context.Context, j *job, file *os.File, size int64, compression, workerPath string) error {
+	splits, err := compressed.Splits(file, size, compression, chunkSize)
+	if err != nil {
+		return fmt.Errorf("split %s file: %w", compression, err)
+	}
+	if len(splits) == 1 && splits[0].RawLength < 0 {
+		log.Printf("[MASTER] %s file %s cannot be split without decompressing it, so the master decompresses it and sends its chunks; recompress it with bgzip or zstd --seekable to let the workers read it", compression, workerPath)
+		input, err := compressed.NewReader(io.NewSectionReader(file, 0, size), compression)
+		if err != nil {
+			return fmt.Errorf("open %s stream: %w", compression, err)
+		}
+		defer input.Close()
+		return readChunks(ctx, j, input)
+	}
+	var raw int64
+	for _, s := range splits {
+		err := j.submit(ctx, &pb.MapRequest{
+			Path:        workerPath,
+			Offset:      s.Offset,
+			Length:      s.Length,
+			Compression: compression,
+			RawOffset:   s.RawOffset,
+			RawLength:   s.RawLength,
+			LineStart:   s.LineStart,
+		})
+		if err != nil {
+			return err
+		}
+		raw += s.RawLength
+	}
+	log.Printf("[MASTER] Planned %d ranges of %s (%d %s bytes, %d decompressed)", len(splits), workerPath, size, compression, raw)
+	return nil
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/reservoir"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// recordingMapper is a worker that records the map requests it receives,
+// with the frames of streamed chunks joined back together
+type recordingMapper struct {
+	pb.UnimplementedMapReduceServiceServer
+	mu       sync.Mutex
+	requests []*pb.MapRequest
+}
+
+func (r *recordingMapper) ProcessMap(ctx context.Context, req *pb.MapRequest) (*pb.MapResponse, error) {
+	r.record(req)
+	return &pb.MapResponse{}, nil
+}
+
+func (r *recordingMapper) ProcessMapStream(stream grpc.ClientStreamingServer[pb.MapRequest, pb.MapResponse]) error {
+	var req *pb.MapRequest
+	for {
+		frame, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if req == nil {
+			req = frame
+		} else {
+			req.LogData = append(req.LogData, frame.LogData...)
+		}
+	}
+	r.record(req)
+	return stream.SendAndClose(&pb.MapResponse{})
+}
+
+func (r *recordingMapper) record(req *pb.MapRequest) {
+	r.mu.Lock()
+	r.requests = append(r.requests, req)
+	r.mu.Unlock()
+}
+
+// newTestJob returns a job whose chunks are sent to the worker, which is
+// served for the duration of the test
+func newTestJob(t *testing.T, worker pb.MapReduceServiceServer) *job {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(grpc.MaxRecvMsgSize(64 << 20))
+	pb.RegisterMapReduceServiceServer(server, worker)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+	members := newMembership(time.Second, time.Second, 0, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	t.Cleanup(members.close)
+	if _, err := members.register(lis.Addr().String(), 2, 2, "test"); err != nil {
+		t.Fatal(err)
+	}
+	return &job{
+		id:            "job-test",
+		sched:         newScheduler(members, 1, time.Millisecond, time.Minute),
+		shuf:          newShuffle(1),
+		spec:          &pb.QuerySpec{GroupBy: []string{"status"}},
+		numPartitions: 1,
+		frameSize:     4 << 20,
+		memory:        newBudget(4 * chunkSize),
+		rejected:      reservoir.New[*pb.RejectedLine](0),
+		formatName:    "combined",
+	}
+}
+
+// testLines returns n lines of the combined format
+func testLines(n int) []byte {
+	var b bytes.Buffer
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "10.0.0.%d - - [10/Oct/2000:13:55:36 -0700] \"GET /%d HTTP/1.0\" 200 %d\n", i%250, i, i)
+	}
+	return b.Bytes()
+}
+
+// wait waits for the chunks of a job and returns the map requests the
+// worker received, ordered by offset
+func wait(t *testing.T, j *job, worker *recordingMapper) []*pb.MapRequest {
+	t.Helper()
+	if lost := j.sched.wait(); len(lost) > 0 {
+		t.Fatalf("lost %v", lost)
+	}
+	requests := worker.requests
+	for i := 1; i < len(requests); i++ {
+		for k := i; k > 0 && requests[k].Offset < requests[k-1].Offset; k-- {
+			requests[k], requests[k-1] = requests[k-1], requests[k]
+		}
+	}
+	return requests
+}
+
+func TestUnsplittableCompressedFileIsStreamed(t *testing.T) {
+	// concatenated gzip members whose boundaries cannot be found without
+	// decompressing, decompressing to more than two chunks
+	lines := testLines(1)
+	var raw bytes.Buffer
+	for raw.Len() < 2*chunkSize+chunkSize/2 {
+		raw.Write(lines)
+	}
+	var file bytes.Buffer
+	for _, part := range [][]byte{raw.Bytes()[:raw.Len()/2], raw.Bytes()[raw.Len()/2:]} {
+		w, _ := gzip.NewWriterLevel(&file, gzip.BestSpeed)
+		w.Write(part)
+		w.Close()
+	}
+	path := filepath.Join(t.TempDir(), "access.log.gz")
+	if err := os.WriteFile(path, file.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	worker := &recordingMapper{}
+	j := newTestJob(t, worker)
+	j.startFile(path)
+	if err := readInput(context.Background(), j, path, true, ""); err != nil {
+		t.Fatal(err)
+	}
+	requests := wait(t, j, worker)
+	if len(requests) < 3 {
+		t.Fatalf("sent %d map requests, want the file in chunks", len(requests))
+	}
+	var joined []byte
+	for _, req := range requests {
+		if req.Path != "" || req.JobId != "job-test" {
+			t.Fatalf("request for %q of job %q, want chunk data", req.Path, req.JobId)
+		}
+		joined = append(joined, req.LogData...)
+		joined = append(joined, '\n')
+	}
+	if !bytes.Equal(joined, raw.Bytes()) {
+		t.Errorf("chunks join to %d bytes, want the %d decompressed bytes", len(joined), raw.Len())
+	}
+}
+
+// checkChunks fails unless the chunks of requests hold want, each at its
+// offset in input
+func checkChunks(t *testing.T, requests []*pb.MapRequest, input, want []byte) {
+	t.Helper()
+	var joined []byte
+	for _, req := range requests {
+		if !bytes.HasPrefix(input[req.Offset:], req.LogData) {
+			t.Errorf("chunk at offset %d does not hold the data at that offset", req.Offset)
+		}
+		joined = append(joined, req.LogData...)
+		joined = append(joined, '\n')
+	}
+	if !bytes.Equal(joined, want) {
+		t.Errorf("chunks join to %d bytes, want %d", len(joined), len(want))
+	}
+}
+
+func TestReadChunksRejectsLongLineOnce(t *testing.T) {
+	before, after := testLines(3), testLines(2)
+	last := bytes.TrimSuffix(testLines(1), []byte{'\n'})
+	// a line that ends in the chunk after the one it starts in is carried
+	// over whole, one that fills a chunk is rejected
+	carried := bytes.Repeat([]byte{'c'}, chunkSize)
+	long := bytes.Repeat([]byte{'x'}, 2*chunkSize)
+	input := slices.Concat(before, carried, []byte{'\n'}, long, []byte{'\n'}, after, last)
+
+	worker := &recordingMapper{}
+	j := newTestJob(t, worker)
+	j.rejected = reservoir.New[*pb.RejectedLine](10)
+	j.startFile("pipe")
+	r, w := io.Pipe()
+	go func() {
+		// a pipe returns little data per read
+		for rest := input; len(rest) > 0; {
+			n, _ := w.Write(rest[:min(len(rest), 1<<20)])
+			rest = rest[n:]
+		}
+		w.Close()
+	}()
+	if err := readChunks(context.Background(), j, r); err != nil {
+		t.Fatal(err)
+	}
+	// the last line is flushed at EOF without its newline
+	checkChunks(t, wait(t, j, worker), input, slices.Concat(before, carried, []byte{'\n'}, after, last, []byte{'\n'}))
+	if lines := j.lineTotals(); lines.lines != 1 || lines.rejected != 1 {
+		t.Errorf("master counted %d lines, %d rejected, want the long line rejected once", lines.lines, lines.rejected)
+	}
+	if rejected := j.rejectedLines(); len(rejected) != 1 || rejected[0].Offset != int64(len(before)+len(carried)+1) || len(rejected[0].Line) != maxRejectedLineBytes {
+		t.Errorf("rejected sample %v, want the start of the line at offset %d", rejected, len(before)+len(carried)+1)
+	}
+}
+
+func TestReadInputFromPipes(t *testing.T) {
+	input := slices.Concat(testLines(5), []byte("10.0.0.1 - - [10/Oct/2000:13:55:36 -0700] \"GET / HTTP/1.0\" 200 1"))
+	for _, c := range []struct {
+		name string
+		// open returns the input to read and a function that opens its
+		// writing end, called while the input is read
+		open func(t *testing.T) (string, func() (io.WriteCloser, error))
+	}{
+		{"fifo", func(t *testing.T) (string, func() (io.WriteCloser, error)) {
+			path := filepath.Join(t.TempDir(), "fifo")
+			if err := syscall.Mkfifo(path, 0o600); err != nil {
+				t.Skipf("named pipes are not supported: %v", err)
+			}
+			return path, func() (io.WriteCloser, error) {
+				// opening a named pipe waits for the other end
+				return os.OpenFile(path, os.O_WRONLY, 0)
+			}
+		}},
+		{"stdin", func(t *testing.T) (string, func() (io.WriteCloser, error)) {
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			stdin := os.Stdin
+			os.Stdin = r
+			t.Cleanup(func() {
+				os.Stdin = stdin
+				r.Close()
+			})
+			return stdinPath, func() (io.WriteCloser, error) { return w, nil }
+		}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			path, writer := c.open(t)
+			worker := &recordingMapper{}
+			j := newTestJob(t, worker)
+			j.startFile(path)
+			written := make(chan error, 1)
+			go func() {
+				w, err := writer()
+				if err == nil {
+					_, err = w.Write(input)
+					w.Close()
+				}
+				written <- err
+			}()
+			if err := readInput(context.Background(), j, path, false, ""); err != nil {
+				t.Fatal(err)
+			}
+			if err := <-written; err != nil {
+				t.Fatal(err)
+			}
+			// the last line is flushed at EOF without its newline
+			checkChunks(t, wait(t, j, worker), input, append(input, '\n'))
+		})
+	}
+}
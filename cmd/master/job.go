@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/ddsketch"
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/logformat"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/query"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/reservoir"
+)
+
+// job holds the settings shared by every map task of a run and hands the
+// chunks produced by the input to the scheduler
+type job struct {
+	// id is sent with every request, and workers check it against the job
+	// credential of the call
+	id    string
+	sched *scheduler
+	shuf  *shuffle
+	// heavy merges the sketches of the chunks in -top sketch mode, which
+	// skips the shuffle and reduce phases
+	heavy *heavyHitters
+	// window keeps rolling counts in follow mode, which also skips the
+	// shuffle and reduce phases
+	window *window
+	// distinct merges the distinct value sketches of each group when the
+	// query asks for them
+	distinct *distinctCounts
+	// numeric merges the summaries of the aggregate field of each group when
+	// the query asks for them
+	numeric       *numericStats
+	spec          *pb.QuerySpec
+	numPartitions int
+	frameSize     int
+	// memory bounds the chunk data held while chunks are in flight
+	memory *budget
+	// formatName is the -format flag; format and formatHeader are resolved
+	// from the first chunk of every file
+	formatName   string
+	format       *logformat.Format
+	formatHeader string
+	// formats lists the formats of the files read so far
+	formats []string
+	// source is the file being read
+	source string
+	// chunks is the number of chunks submitted so far
+	chunks int
+	// lines sums the line statistics of the collected chunks and rejected
+	// samples their rejected lines
+	linesMu  sync.Mutex
+	lines    lineCounts
+	rejected *reservoir.Sample[*pb.RejectedLine]
+}
+
+// lineCounts counts the lines of the job by outcome, see pb.MapStats
+type lineCounts struct {
+	lines, matched, rejected, skipped, filtered int64
+}
+
+// startFile makes path the file whose chunks are submitted next. Its format
+// is resolved again from its first chunk, so files of a job may differ in
+// format or header.
+func (j *job) startFile(path string) {
+	j.source = path
+	j.format, j.formatHeader = nil, ""
+}
+
+// resolve picks the log format from a sample of the first chunk of a file.
+// It is a no-op once the format is known.
+func (j *job) resolve(sample []byte) error {
+	if j.format != nil {
+		return nil
+	}
+	format, header, err := resolveFormat(j.formatName, sample, j.spec)
+	if err != nil {
+		return fmt.Errorf("%s: %w", j.source, err)
+	}
+	j.format, j.formatHeader = format, header
+	if !slices.Contains(j.formats, format.Name) {
+		j.formats = append(j.formats, format.Name)
+		log.Printf("[MASTER] Using log format %s", format.Name)
+	}
+	return nil
+}
+
+// formatNames returns the formats of the files read, ex. "combined" or
+// "combined, json"
+func (j *job) formatNames() string {
+	return strings.Join(j.formats, ", ")
+}
+
+// submit fills in the job settings of a map request that carries either
+// log_data or a shared-storage byte range, and hands it to the scheduler. It
+// blocks until the chunk's data fits in the memory budget.
+func (j *job) submit(ctx context.Context, req *pb.MapRequest) error {
+	// the chunk holds on to its whole backing array, which can be larger
+	// than its data, ex. the partial line read after it
+	size := int64(cap(req.LogData))
+	if err := j.memory.acquire(ctx, size); err != nil {
+		return err
+	}
+	req.ChunkId = chunkName(j.chunks)
+	req.JobId = j.id
+	req.NumPartitions = int32(j.numPartitions)
+	req.Query = j.spec
+	req.Format = j.format.Name
+	req.FormatHeader = j.formatHeader
+	req.Source = j.source
+	j.chunks++
+	chunksSubmitted.Inc()
+	if req.Path != "" {
+		bytesSubmitted.Add(float64(req.Length))
+	} else {
+		bytesSubmitted.Add(float64(len(req.LogData)))
+	}
+	j.sched.submit(ctx, req.ChunkId, func(ctx context.Context, w *member) error {
+		var (
+			resp *pb.MapResponse
+			err  error
+		)
+		if req.Path != "" {
+			resp, err = sendRange(ctx, req, w)
+		} else {
+			resp, err = sendChunk(ctx, req, j.frameSize, w)
+		}
+		if err != nil {
+			return err
+		}
+		return j.collect(resp)
+	}, func() { j.memory.release(size) })
+	return nil
+}
+
+// collect merges the map output of one chunk into the job results. A failed
+// chunk is retried, so nothing that would be counted twice is merged until
+// every part of the response has been checked; distinct sketches may be
+// merged early as merging them again changes nothing.
+func (j *job) collect(resp *pb.MapResponse) error {
+	var numeric map[string]*ddsketch.Sketch
+	if j.numeric != nil {
+		var err error
+		if numeric, err = j.numeric.load(resp.NumericSummaries); err != nil {
+			return err
+		}
+	}
+	if j.distinct != nil {
+		if err := j.distinct.add(resp.DistinctSketches); err != nil {
+			return err
+		}
+	}
+	switch {
+	case j.heavy != nil:
+		j.heavy.add(resp.HeavyHitters)
+	case j.window != nil:
+		if err := j.window.add(resp.PartialResults); err != nil {
+			return err
+		}
+	default:
+		if err := j.shuf.add(resp.PartialResults); err != nil {
+			return err
+		}
+	}
+	if j.numeric != nil {
+		j.numeric.merge(numeric)
+	}
+	if st := resp.Stats; st != nil {
+		j.linesMu.Lock()
+		j.lines.lines += st.Lines
+		j.lines.matched += st.Matched
+		j.lines.rejected += st.Rejected
+		j.lines.skipped += st.Skipped
+		j.lines.filtered += st.Filtered
+		for outcome, n := range map[string]int64{"read": st.Lines, "matched": st.Matched, "rejected": st.Rejected, "skipped": st.Skipped, "filtered": st.Filtered} {
+			linesCollected.Add(float64(n), outcome)
+		}
+		j.rejected.Merge(reservoir.FromItems(j.rejected.Size(), st.Rejected, st.RejectedSample))
+		j.linesMu.Unlock()
+	}
+	return nil
+}
+
+// maxRejectedLineBytes is how much of a line the master rejects is sampled,
+// as the workers do
+const maxRejectedLineBytes = 512
+
+// rejectLine counts a line the master rejects without sending it, starting
+// with start at offset of the current file
+func (j *job) rejectLine(offset int64, start []byte) {
+	if len(start) > maxRejectedLineBytes {
+		start = start[:maxRejectedLineBytes]
+	}
+	line := &pb.RejectedLine{Offset: offset, Line: strings.ToValidUTF8(string(start), "\uFFFD"), Source: j.source}
+	j.linesMu.Lock()
+	j.lines.lines++
+	j.lines.rejected++
+	linesCollected.Add(1, "read")
+	linesCollected.Add(1, "rejected")
+	j.rejected.Merge(reservoir.FromItems(j.rejected.Size(), 1, []*pb.RejectedLine{line}))
+	j.linesMu.Unlock()
+}
+
+// lineTotals returns the line statistics of the chunks collected so far
+func (j *job) lineTotals() lineCounts {
+	j.linesMu.Lock()
+	defer j.linesMu.Unlock()
+	return j.lines
+}
+
+// rejectedLines returns the sample of rejected lines ordered by file and
+// offset
+func (j *job) rejectedLines() []*pb.RejectedLine {
+	j.linesMu.Lock()
+	lines := append([]*pb.RejectedLine(nil), j.rejected.Items()...)
+	j.linesMu.Unlock()
+	sort.Slice(lines, func(a, b int) bool {
+		if lines[a].Source != lines[b].Source {
+			return lines[a].Source < lines[b].Source
+		}
+		return lines[a].Offset < lines[b].Offset
+	})
+	return lines
+}
+
+// sendRange asks a worker to read and process a byte range of a file on
+// shared storage and returns its map output
+func sendRange(ctx context.Context, req *pb.MapRequest, w *member) (*pb.MapResponse, error) {
+	// Send the request to the worker
+	resp, err := w.client.ProcessMap(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("process map on %s: %w", w.address, err)
+	}
+	return resp, nil
+}
+
+// logGroups prints the distinct counts and numeric statistics the query asked
+// for, for the given keys or for every group when keys is nil
+func (j *job) logGroups(keys []string, percentiles []float64) {
+	groupBy := query.GroupBy(j.spec.GroupBy)
+	if j.distinct != nil {
+		logDistinct(groupBy, j.distinct, keys)
+	}
+	if j.numeric != nil {
+		logNumeric(groupBy, j.numeric, keys, percentiles)
+	}
+}
@@ -1,157 +1,571 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"os"
-	"sync"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/auth"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/ddsketch"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/filter"
 	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/hll"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/logformat"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/query"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/reservoir"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/tlsconfig"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/topk"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
 )
 
-// For now, we will hardcode the worker addresses
-var workers = []string{
-	"127.0.0.1:50051",
-	// add more workers here
-}
-
 const chunkSize = 50 * 1024 * 1024
 
+// maxFrameSize caps -frame-size below the 64MB message limit of the worker
+const maxFrameSize = 32 * 1024 * 1024
+
 func main() {
 	log.Println("[MASTER] Starting master server...")
 	// Parse the command line flags
-	filename := flag.String("file", "", "Path to the log file")
+	var inputs fileList
+	flag.Var(&inputs, "file", "Path, glob pattern or directory of log files, or - for standard input; repeat it or list more paths after the flags to read several as one job")
+	recursive := flag.Bool("recursive", false, "Also read the files in subdirectories of -file directories")
+	var include, exclude patternList
+	flag.Var(&include, "include", "Only read files of -file directories and patterns whose name matches one of these patterns, separated by commas (ex. '*.log,*.gz')")
+	flag.Var(&exclude, "exclude", "Skip files of -file directories and patterns whose name matches one of these patterns, separated by commas")
+	listenAddr := flag.String("listen", ":50050", "Address workers use to register with the master")
+	minWorkers := flag.Int("min-workers", 1, "Number of workers that must register before processing starts")
+	registerTimeout := flag.Duration("register-timeout", time.Minute, "How long to wait for -min-workers to register")
+	heartbeatInterval := flag.Duration("heartbeat-interval", 2*time.Second, "How often workers must send a heartbeat")
+	heartbeatTimeout := flag.Duration("heartbeat-timeout", 6*time.Second, "Evict workers that miss heartbeats for this long")
+	maxAttempts := flag.Int("max-attempts", 3, "Maximum number of attempts per chunk before the job fails")
+	retryBackoff := flag.Duration("retry-backoff", 500*time.Millisecond, "Initial delay before retrying a failed chunk, doubled on every attempt")
+	chunkTimeout := flag.Duration("chunk-timeout", 5*time.Minute, "Maximum time a worker may spend on a single chunk attempt")
+	formatName := flag.String("format", logformat.Auto, "Log format ("+logformat.Auto+" or one of "+strings.Join(logformat.Names(), ", ")+")")
+	groupBySpec := flag.String("group-by", "status", "Fields to group by, joined with '+' (ex. ip, status, method+path, status+hour)")
+	frameSize := flag.Int("frame-size", 1024*1024, "Maximum size of a frame when streaming a chunk to a worker")
+	maxInFlight := flag.Int("max-inflight-per-worker", 0, "Maximum chunks dispatched to one worker at once (defaults to the capacity the worker reports, or its parallelism without one)")
+	memoryBudget := flag.Int("memory-budget-mb", 1024, "Maximum chunk data in MB the master holds while chunks are in flight")
+	shared := flag.Bool("shared", false, "Workers read byte ranges of the file from shared storage instead of receiving the data")
+	sharedPath := flag.String("shared-path", "", "Path of the file as seen by the workers in -shared mode (defaults to the absolute -file path)")
+	reducers := flag.Int("reducers", 0, "Number of reduce partitions (defaults to the number of live workers)")
+	top := flag.Int("top", 0, "Only report the N most frequent keys (0 reports every key)")
+	topMode := flag.String("top-mode", topModeSketch, "How -top finds the most frequent keys: "+topModeSketch+" (approximate, bounded memory) or "+topModeExact+" (counts every key)")
+	topCapacity := flag.Int("top-capacity", 0, "Number of counters in each -top sketch (defaults to 10 times -top, at least 1000)")
+	distinct := flag.String("distinct", "", "Also estimate the number of distinct values of this field per group (ex. ip, user_agent)")
+	bucketSpec := flag.String("bucket", "", "Also group lines into time buckets of this size: minute, hour, day or a duration such as 5m")
+	timezone := flag.String("timezone", "UTC", "Timezone -bucket aligns buckets in, the hour and day fields are given in and -filter reads times without an offset in (ex. UTC, Local, America/New_York)")
+	filterExpr := flag.String("filter", "", "Only count lines matching this expression (ex. 'status >= 500 and path starts with /api')")
+	aggField := flag.String("agg", "", "Also report count, sum, min, max, mean and percentiles of this numeric field per group (ex. size, request_time)")
+	aggAccuracy := flag.Float64("agg-accuracy", ddsketch.DefaultAccuracy, "Relative error of the -agg percentiles")
+	percentileSpec := flag.String("percentiles", "50,95,99", "Percentiles reported for -agg, separated by commas")
+	output := flag.String("output", "", "Also write the results and a job summary to this file, or to stdout if -")
+	outputFormatName := flag.String("output-format", "", "Format of -output: "+outputJSON+", "+outputCSV+" or "+outputTable+" (defaults to the file extension, else "+outputTable+")")
+	rejectSample := flag.Int("reject-sample", 10, "Number of rejected lines to sample for the data quality report")
+	maxRejectRatio := flag.Float64("max-reject-ratio", 1, "Fail the job when more than this fraction of the lines cannot be parsed (ex. 0.01)")
+	followMode := flag.Bool("follow", false, "Keep reading the file as it grows, like tail -F, and report rolling counts until interrupted")
+	followFromStart := flag.Bool("follow-from-start", false, "In -follow mode, read the lines already in the file first instead of starting at its end")
+	followInterval := flag.Duration("follow-interval", time.Second, "How often -follow checks the file for new lines")
+	windowSpan := flag.Duration("window", 5*time.Minute, "In -follow mode, report the counts of this last span of the log, by the times of its lines (0 counts everything since the start)")
+	reportInterval := flag.Duration("report-interval", 10*time.Second, "How often -follow prints the counts and rewrites -output")
+	var tlsConfig tlsconfig.Config
+	tlsConfig.RegisterFlags(flag.CommandLine)
+	authToken := flag.String("auth-token-file", "", "Send the bearer token in this file to the workers")
+	authJobKey := flag.String("auth-job-key-file", "", "Send the workers job credentials signed with the key in this file, and only accept registrations with credentials signed with it (also see -auth-tokens)")
+	authTokens := flag.String("auth-tokens", "", "Only accept worker registrations with a bearer token listed in this file, one 'name token' per line")
+	metricsAddr := flag.String("metrics-listen", "", "Serve Prometheus metrics at /metrics on this address (ex. :9090)")
+	authTTL := flag.Duration("auth-credential-ttl", time.Minute, "How long a job credential is valid after it is sent; allow for clock skew between the machines")
+	distinctPrecision := flag.Int("distinct-precision", hll.DefaultPrecision, fmt.Sprintf("HyperLogLog precision for -distinct, between %d and %d; each step halves the error", hll.MinPrecision, hll.MaxPrecision))
 	flag.Parse()
 
 	//validate the filename
-	if *filename == "" {
-		log.Fatal("Please provide a log file using -file flag")
+	inputs = append(inputs, flag.Args()...)
+	if len(inputs) == 0 {
 		flag.Usage()
-		os.Exit(1)
+		log.Fatal("Please provide a log file using -file flag")
+	}
+	files, err := inputFiles(inputs, *recursive, include, exclude)
+	if err != nil {
+		log.Fatalf("Invalid -file: %v", err)
+	}
+	if *sharedPath != "" && len(files) > 1 {
+		log.Fatal("Invalid -shared-path: it names a single file, but -file matches several")
+	}
+
+	if *followMode {
+		if len(files) != 1 {
+			log.Fatal("Invalid -follow: it follows a single file, but -file matches several")
+		}
+		if files[0] == stdinPath {
+			log.Fatal("Invalid -follow: standard input is read to its end without -follow")
+		}
+		if *shared {
+			log.Fatal("Invalid -follow: the master reads the new lines, so it cannot be used with -shared")
+		}
+		if *distinct != "" || *aggField != "" {
+			log.Fatal("Invalid -follow: rolling counts do not support -distinct or -agg")
+		}
+		if *followInterval <= 0 || *reportInterval <= 0 {
+			log.Fatal("Invalid -follow-interval or -report-interval: must be positive")
+		}
+		if *windowSpan < 0 || (*windowSpan > 0 && *windowSpan < time.Second) {
+			log.Fatal("Invalid -window: must be 0 or at least 1s")
+		}
+		// the window keeps exact counts, so -top ranks them exactly
+		*topMode = topModeExact
+	}
+
+	if *frameSize <= 0 || *frameSize > maxFrameSize {
+		log.Fatalf("Invalid -frame-size: must be between 1 and %d bytes", maxFrameSize)
 	}
 
-	// Process the log file
-	fmt.Printf("Processing log file: %s\n", *filename)
-	file, err := os.Open(*filename)
+	outFormat, err := outputFormat(*outputFormatName, *output)
 	if err != nil {
-		log.Fatalf("Failed to open file: %v", err)
+		log.Fatalf("Invalid -output-format: %v", err)
 	}
-	defer file.Close()
-	// Create a buffered 10MB scanner
-	scanner := bufio.NewScanner(file)
-	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
-	// Instantiate partialResults variable to store all partial results
-	var (
-		allPartialResults []*pb.PartialResult
-		mu                sync.Mutex
-	)
-	// Create a WaitGroup to wait for all workers to finish
-	var wg sync.WaitGroup
-	// instantiate chunkID and workerIndex variables
-	chunkID := 0
-	workerIndex := 0
-	// create a buffer to store the chunk data
-	buffer := make([]byte, chunkSize)
-	leftover := make([]byte, 0)
-	// Read the file in chunks and send each chunk to a worker
-	for {
-		n, err := file.Read(buffer)
-		// Break if we didnt read anything
-		if n == 0 {
-			break
-		}
-		// add delta to the waitgroup counter
-		wg.Add(1)
-		// append the leftover data from the previous chunk
-		data := append(leftover, buffer[:n]...)
-		// find the last newline character in the chunk
-		lastNewline := bytes.LastIndexByte(data, '\n')
-		if lastNewline == -1 {
-			lastNewline = len(data)
-		}
-		// split the chunk at the last newline character
-		chunk := data[:lastNewline]
-		// store the leftover data for the next chunk
-		leftover = data[lastNewline+1:]
-		// send the chunk to a worker
-		go sendChunk(chunkID, chunk, workers[workerIndex%len(workers)], &wg, &allPartialResults, &mu)
-		chunkID++
-		workerIndex++
-		if err == io.EOF {
-			break
+
+	// Validate the query before any worker is involved
+	groupBy, err := query.ParseGroupBy(*groupBySpec)
+	if err != nil {
+		log.Fatalf("Invalid -group-by: %v", err)
+	}
+	spec := &pb.QuerySpec{GroupBy: groupBy}
+	if *rejectSample < 0 || *rejectSample > 1000 {
+		log.Fatal("Invalid -reject-sample: must be between 0 and 1000")
+	}
+	spec.RejectSample = int32(*rejectSample)
+	if *maxRejectRatio < 0 || *maxRejectRatio > 1 {
+		log.Fatal("Invalid -max-reject-ratio: must be between 0 and 1")
+	}
+	if *top < 0 {
+		log.Fatal("Invalid -top: must not be negative")
+	}
+	if *topMode != topModeSketch && *topMode != topModeExact {
+		log.Fatalf("Invalid -top-mode %q: must be %s or %s", *topMode, topModeSketch, topModeExact)
+	}
+	sketched := *top > 0 && *topMode == topModeSketch
+	if sketched {
+		if *topCapacity == 0 {
+			*topCapacity = sketchCapacity(*top)
+		}
+		if *topCapacity < *top {
+			log.Fatalf("Invalid -top-capacity: must be at least -top (%d)", *top)
+		}
+		spec.SketchCapacity = int32(*topCapacity)
+	}
+	if *distinct != "" {
+		if _, err := hll.New(*distinctPrecision); err != nil {
+			log.Fatalf("Invalid -distinct-precision: %v", err)
+		}
+		spec.Distinct = *distinct
+		spec.DistinctPrecision = int32(*distinctPrecision)
+	}
+	loc, err := time.LoadLocation(*timezone)
+	if err != nil {
+		log.Fatalf("Invalid -timezone: %v", err)
+	}
+	spec.Timezone = *timezone
+	if *filterExpr != "" {
+		if spec.Filter, err = filter.Compile(*filterExpr, loc); err != nil {
+			log.Fatalf("Invalid -filter: %v", err)
+		}
+	}
+	var bucket *query.Bucket
+	if *bucketSpec != "" {
+		size, err := query.ParseBucketSize(*bucketSpec)
+		if err != nil {
+			log.Fatalf("Invalid -bucket: %v", err)
+		}
+		if bucket, err = query.NewBucket(size, *timezone); err != nil {
+			log.Fatalf("Invalid -timezone: %v", err)
+		}
+		spec.BucketSeconds = int64(size / time.Second)
+	}
+	percentiles, err := parsePercentiles(*percentileSpec)
+	if err != nil {
+		log.Fatalf("Invalid -percentiles: %v", err)
+	}
+	if *aggField != "" {
+		if _, err := ddsketch.New(*aggAccuracy); err != nil {
+			log.Fatalf("Invalid -agg-accuracy: %v", err)
+		}
+		spec.Aggregate = *aggField
+		spec.AggregateAccuracy = *aggAccuracy
+	}
+	if *formatName != logformat.Auto {
+		format, err := logformat.Lookup(*formatName)
+		if err != nil {
+			log.Fatalf("Invalid -format: %v", err)
+		}
+		if err := validateQuery(format, spec); err != nil {
+			log.Fatalf("Invalid query: %v", err)
+		}
+	}
+
+	// The master serves registrations and dials workers with the same TLS
+	// settings
+	tlsConfig.Logf = func(format string, args ...any) {
+		log.Printf("[MASTER] TLS: "+format, args...)
+	}
+	serverCreds, dialCreds, err := tlsConfig.Credentials()
+	if err != nil {
+		log.Fatalf("Invalid TLS settings: %v", err)
+	}
+
+	dialOpts := []grpc.DialOption{dialCreds}
+	// Authenticate to the workers with a token or a credential for this job
+	jobID := "job-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	var key []byte
+	if *authJobKey != "" {
+		if key, err = auth.LoadSecret(*authJobKey); err != nil {
+			log.Fatalf("Invalid -auth-job-key-file: %v", err)
+		}
+	}
+	switch {
+	case *authToken != "" && key != nil:
+		log.Fatal("Invalid authentication settings: use -auth-token-file or -auth-job-key-file, not both")
+	case *authToken != "":
+		token, err := auth.LoadSecret(*authToken)
+		if err != nil {
+			log.Fatalf("Invalid -auth-token-file: %v", err)
+		}
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(auth.NewTokenCredentials(string(token), tlsConfig.Enabled())))
+	case key != nil:
+		if *authTTL <= 0 {
+			log.Fatal("Invalid -auth-credential-ttl: must be positive")
+		}
+		creds, err := auth.NewJobCredentials(key, auth.AudienceWorker, jobID, *authTTL, tlsConfig.Enabled())
+		if err != nil {
+			log.Fatalf("Invalid authentication settings: %v", err)
 		}
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(creds))
+		log.Printf("[MASTER] Sending credentials for %s to the workers", jobID)
+	}
+	// With -auth-tokens or a job key, workers must authenticate to register
+	// and send heartbeats
+	serverOpts := []grpc.ServerOption{serverCreds}
+	if *authTokens != "" || key != nil {
+		var tokens []auth.Token
+		if *authTokens != "" {
+			if tokens, err = auth.LoadTokens(*authTokens); err != nil {
+				log.Fatalf("Invalid -auth-tokens: %v", err)
+			}
+		}
+		verifier, err := auth.NewVerifier(tokens, key, auth.AudienceMaster)
 		if err != nil {
-			log.Fatalf("Failed to read chunk: %v", err)
+			log.Fatalf("Invalid authentication settings: %v", err)
 		}
+		authn := &auth.Interceptor{
+			Verifier: verifier,
+			Logf: func(format string, args ...any) {
+				log.Printf("[MASTER] "+format, args...)
+			},
+			Quiet: map[string]bool{"Heartbeat": true},
+		}
+		serverOpts = append(serverOpts, grpc.ChainUnaryInterceptor(authn.Unary), grpc.ChainStreamInterceptor(authn.Stream))
+	}
+	if (len(dialOpts) > 1 || len(serverOpts) > 1) && !tlsConfig.Enabled() {
+		log.Println("[MASTER] Warning: credentials are sent in cleartext without -tls-cert")
 	}
-	log.Print("Finished reading the file")
 
-	if err := scanner.Err(); err != nil {
+	// Start the membership server so workers can register
+	members := newMembership(*heartbeatInterval, *heartbeatTimeout, *maxInFlight, dialOpts...)
+	memory := newBudget(int64(*memoryBudget) * 1024 * 1024)
+	if *metricsAddr != "" {
+		registry.NewGaugeFunc("loganalyzer_master_workers", "Registered workers.", func() float64 {
+			return float64(members.size())
+		})
+		registry.NewGaugeFunc("loganalyzer_master_memory_in_use_bytes", "Chunk data held while chunks are in flight.", func() float64 {
+			return float64(memory.inUse())
+		})
+		metricsLis, err := net.Listen("tcp", *metricsAddr)
+		if err != nil {
+			log.Fatalf("Failed to listen for metrics: %v", err)
+		}
+		go func() {
+			if err := registry.Serve(metricsLis); err != nil {
+				log.Fatalf("Failed to serve metrics: %v", err)
+			}
+		}()
+		log.Printf("[MASTER] Serving metrics on %s/metrics", *metricsAddr)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go members.run(ctx)
+	defer members.close()
+	lis, err := net.Listen("tcp", *listenAddr)
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+	grpcServer := grpc.NewServer(serverOpts...)
+	pb.RegisterMapReduceServiceServer(grpcServer, &masterServer{members: members})
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Fatalf("Failed to serve: %v", err)
+		}
+	}()
+	defer grpcServer.Stop()
+	log.Printf("[MASTER] Waiting for %d worker(s) to register on %s", *minWorkers, *listenAddr)
+	waitCtx, waitCancel := context.WithTimeout(ctx, *registerTimeout)
+	err = members.waitFor(waitCtx, *minWorkers)
+	waitCancel()
+	if err != nil {
+		log.Fatalf("Failed to start job: %v", err)
+	}
+	workers := members.size()
+	log.Printf("[MASTER] %d worker(s) live, starting job", workers)
+	started := time.Now()
+	numPartitions := *reducers
+	if *followMode {
+		// follow mode merges the map output itself
+		numPartitions = 1
+	} else if numPartitions <= 0 {
+		numPartitions = members.size()
+	}
+
+	// Create a scheduler that tracks every task and retries failed ones
+	sched := newScheduler(members, *maxAttempts, *retryBackoff, *chunkTimeout)
+	// Map output is collected per reduce partition
+	shuf := newShuffle(numPartitions)
+	j := &job{
+		id:            jobID,
+		sched:         sched,
+		shuf:          shuf,
+		spec:          spec,
+		numPartitions: numPartitions,
+		frameSize:     *frameSize,
+		memory:        memory,
+		rejected:      reservoir.New[*pb.RejectedLine](*rejectSample),
+		formatName:    *formatName,
+	}
+	if sketched {
+		// Workers summarise each chunk in a sketch that is merged here
+		j.heavy = newHeavyHitters(*topCapacity)
+	}
+	if *distinct != "" {
+		j.distinct = newDistinctCounts(*distinct, *distinctPrecision)
+	}
+	if *aggField != "" {
+		j.numeric = newNumericStats(*aggField, *aggAccuracy)
+	}
+	if *followMode {
+		j.window = newWindow(*windowSpan)
+		spec.WindowSeconds = j.window.slotSeconds()
+		publish := func() {
+			counters := logWindow(groupBy, bucket, *top, j.window.String(), j.window.counters())
+			if lost := sched.drain(); len(lost) > 0 {
+				log.Printf("[MASTER] %d batch(es) lost after %d attempts are missing from the counts: %v", len(lost), *maxAttempts, lost)
+			}
+			if *output == "" {
+				return
+			}
+			r := &report{
+				groupBy:  groupBy,
+				bucket:   bucket,
+				counters: counters,
+				summary:  newSummary(files, j, workers, time.Since(started)),
+			}
+			if err := replaceReport(r, *output, outFormat); err != nil {
+				log.Printf("[MASTER] Failed to write output: %v", err)
+			}
+		}
+		// Follow the file until Ctrl-C or SIGTERM, then report one last time
+		stopCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		err := follow(ctx, stopCtx.Done(), j, files[0], followOptions{
+			fromStart:   *followFromStart,
+			interval:    *followInterval,
+			reportEvery: *reportInterval,
+		}, publish)
+		stop()
+		if err != nil {
+			log.Fatalf("Failed to follow file: %v", err)
+		}
+		log.Printf("[MASTER] Stopped following %s, waiting for the batches in flight", files[0])
+		if lost := sched.wait(); len(lost) > 0 {
+			log.Printf("[MASTER] %d batch(es) lost after %d attempts are missing from the counts: %v", len(lost), *maxAttempts, lost)
+		}
+		publish()
+		logQuality(j.formatNames(), j.lineTotals(), j.rejectedLines())
+		logSummary(newSummary(files, j, workers, time.Since(started)))
+		return
+	}
+	// Read every file as part of one job
+	if err := readInputs(ctx, j, files, *shared, *sharedPath); err != nil {
 		log.Fatalf("Failed to read file: %v", err)
 	}
+	log.Printf("Finished reading %d file(s)", len(files))
+
+	// Wait for all chunks to finish
+	if lost := sched.wait(); len(lost) > 0 {
+		log.Fatalf("[MASTER] Job failed: %d chunk(s) lost after %d attempts: %v", len(lost), *maxAttempts, lost)
+	}
+	lines := j.lineTotals()
+	logQuality(j.formatNames(), lines, j.rejectedLines())
+	if err := checkRejectRatio(lines, *maxRejectRatio); err != nil {
+		log.Fatalf("[MASTER] Job failed: %v", err)
+	}
+	var counters []topk.Counter
+	if sketched {
+		var rest int64
+		counters, rest = j.heavy.top(*top)
+		log.Printf("[MASTER] Merged the sketches of %d chunks", j.heavy.chunks)
+		logTop(groupBy, fmt.Sprintf("Space-Saving, %d counters", *topCapacity), counters, rest)
+	} else {
+		log.Printf("[MASTER] Received %d partial results in %d partitions", shuf.size(), numPartitions)
 
-	// Wait for all workers to finish
-	wg.Wait()
-	log.Printf("[MASTER] Received all partial results: %v", allPartialResults)
-	aggregate := localReduce(allPartialResults)
-	log.Printf("[MASTER] Final Aggregated results: %v", aggregate)
+		// Reduce every partition on the workers
+		results, lost := shuf.reduce(ctx, sched, jobID)
+		if len(lost) > 0 {
+			log.Fatalf("[MASTER] Job failed: %d partition(s) lost after %d attempts: %v", len(lost), *maxAttempts, lost)
+		}
+		switch {
+		case *top > 0:
+			counters = exactTop(results, *top)
+			logTop(groupBy, "exact", counters, 0)
+		case bucket != nil:
+			logSeries(groupBy, bucket, results)
+			counters = exactTop(results, len(results))
+			sortSeries(counters)
+		default:
+			aggregate := make(map[string]int64, len(results))
+			for _, r := range results {
+				aggregate[r.Key] = r.TotalCount
+			}
+			log.Printf("[MASTER] Final Aggregated results: %v", aggregate)
+			counters = exactTop(results, len(results))
+		}
+	}
+	j.logGroups(counterKeys(counters), percentiles)
+	sum := newSummary(files, j, workers, time.Since(started))
+	logSummary(sum)
+	if *output != "" {
+		r := &report{
+			groupBy:     groupBy,
+			bucket:      bucket,
+			approximate: sketched,
+			counters:    counters,
+			distinct:    j.distinct,
+			numeric:     j.numeric,
+			percentiles: percentiles,
+			summary:     sum,
+		}
+		if err := writeReport(r, *output, outFormat); err != nil {
+			log.Fatalf("Failed to write output: %v", err)
+		}
+		if *output != "-" {
+			log.Printf("[MASTER] Wrote %s results to %s", outFormat, *output)
+		}
+	}
 }
 
+// resolveFormat looks up or, for "auto", detects the log format from a sample
+// of the first chunk, captures the header the format needs for later chunks
+// and checks that the fields of the query exist in the format.
+func resolveFormat(name string, sample []byte, spec *pb.QuerySpec) (*logformat.Format, string, error) {
+	var (
+		format *logformat.Format
+		header string
+		err    error
+	)
+	if name == logformat.Auto {
+		format, header, err = logformat.Detect(sample)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w, use -format to choose one", err)
+		}
+	} else {
+		format, err = logformat.Lookup(name)
+		if err != nil {
+			return nil, "", err
+		}
+		header = logformat.SampleHeader(format, sample)
+	}
+	if err := validateQuery(format, spec); err != nil {
+		return nil, "", fmt.Errorf("log format %s: %w", format.Name, err)
+	}
+	return format, header, nil
+}
 
-func sendChunk(id int, lines []byte, workerAddr string, wg *sync.WaitGroup, allPartialResults *[]*pb.PartialResult, mu *sync.Mutex) {
-	defer wg.Done()
-	// Connect to the worker
-	conn, err := grpc.Dial(workerAddr, grpc.WithInsecure(), grpc.WithDefaultCallOptions(
-		grpc.MaxCallRecvMsgSize(1024*1024*1024),
-		grpc.MaxCallSendMsgSize(1024*1024*1024),
-	))
-	if err != nil {
-		log.Fatalf("Failed to dial worker %s: %v", workerAddr, err)
+// validateQuery checks that every field the query uses exists in the format
+func validateQuery(format *logformat.Format, spec *pb.QuerySpec) error {
+	if err := query.GroupBy(spec.GroupBy).Validate(format.HasField); err != nil {
+		return err
 	}
-	defer conn.Close()
-	// Create a client
-	client := pb.NewMapReduceServiceClient(conn)
+	for _, f := range []struct{ kind, name string }{{"distinct", spec.Distinct}, {"aggregate", spec.Aggregate}} {
+		if f.name != "" && !query.HasField(f.name, format.HasField) {
+			return fmt.Errorf("unknown %s field %q (want one of %s or a field of the log format)", f.kind, f.name, strings.Join(query.Fields, ", "))
+		}
+	}
+	if spec.Filter != nil {
+		if _, err := filter.New(spec.Filter, format.HasField); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	// Create the request for the worker
-	req := &pb.MapRequest{
-		ChunkId: fmt.Sprintf("chunk-%d", id),
-		LogData: lines,
+// sendChunk streams a single chunk to a worker as line-aligned frames of at
+// most frameSize bytes and returns its map output
+func sendChunk(ctx context.Context, req *pb.MapRequest, frameSize int, w *member) (*pb.MapResponse, error) {
+	workerAddr := w.address
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := w.client.ProcessMapStream(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("open map stream on %s: %w", workerAddr, err)
+	}
+	// The first frame carries the job settings, the others only data
+	data := req.LogData
+	frame, data := nextFrame(data, frameSize)
+	first := proto.Clone(req).(*pb.MapRequest)
+	first.LogData = frame
+	if err := stream.Send(first); err != nil {
+		return nil, fmt.Errorf("send frame to %s: %w", workerAddr, streamErr(stream, err))
+	}
+	for len(data) > 0 {
+		frame, data = nextFrame(data, frameSize)
+		if err := stream.Send(&pb.MapRequest{LogData: frame}); err != nil {
+			return nil, fmt.Errorf("send frame to %s: %w", workerAddr, streamErr(stream, err))
+		}
 	}
-	// Send the request to the worker
-	resp, err := client.ProcessMap(context.Background(), req)
+	resp, err := stream.CloseAndRecv()
 	if err != nil {
-		log.Fatalf("Failed to process map: %v", err)
-		return
+		return nil, fmt.Errorf("process map on %s: %w", workerAddr, err)
 	}
-	// Append the partial results to the allPartialResults slice
-	mu.Lock()
-	*allPartialResults = append(*allPartialResults, resp.PartialResults...)
-	mu.Unlock()
+	return resp, nil
 }
 
+// nextFrame cuts the next frame off data, ending it after the last newline
+// within frameSize bytes. A line longer than frameSize is split; the worker
+// joins it back together.
+func nextFrame(data []byte, frameSize int) ([]byte, []byte) {
+	if len(data) <= frameSize {
+		return data, nil
+	}
+	end := bytes.LastIndexByte(data[:frameSize], '\n') + 1
+	if end == 0 {
+		end = frameSize
+	}
+	return data[:end], data[end:]
+}
 
-// localReduce aggregates partial results from multiple sources into a single
-// map. It takes a slice of PartialResult pointers and returns a map where the
-// keys are the same as the PartialResult keys and the values are the sum of
-// the counts for each key.
-//
-// Parameters:
-//   partials - a slice of pointers to PartialResult, each containing a key and
-//              a count.
-//
-// Returns:
-//   A map where each key is a string from the PartialResult and the value is
-//   the total count for that key.
-func localReduce(partials []*pb.PartialResult) map[string]int64 {
-	counts := make(map[string]int64)
-	for _, pr := range partials {
-		counts[pr.Key] += pr.Count
-	}
-	return counts
+// streamErr returns the status the worker closed a stream with, which is more
+// useful than the io.EOF that Send reports when that happens
+func streamErr[Req, Res any](stream grpc.ClientStreamingClient[Req, Res], err error) error {
+	if err != io.EOF {
+		return err
+	}
+	if _, recvErr := stream.CloseAndRecv(); recvErr != nil {
+		return recvErr
+	}
+	return err
 }
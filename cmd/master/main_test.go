@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNextFrame(t *testing.T) {
+	data := []byte("a\nbb\nccc\n" + strings.Repeat("d", 10) + "\ne")
+	for frameSize := 1; frameSize <= len(data)+1; frameSize++ {
+		var joined []byte
+		for rest := data; len(rest) > 0; {
+			var frame []byte
+			frame, rest = nextFrame(rest, frameSize)
+			if len(frame) == 0 || len(frame) > frameSize {
+				t.Fatalf("frame size %d: got a frame of %d bytes", frameSize, len(frame))
+			}
+			// a frame ends at a line boundary unless a line does not fit
+			if len(rest) > 0 && frame[len(frame)-1] != '\n' && bytes.IndexByte(frame, '\n') >= 0 {
+				t.Errorf("frame size %d: frame %q does not end at a line boundary", frameSize, frame)
+			}
+			joined = append(joined, frame...)
+		}
+		if !bytes.Equal(joined, data) {
+			t.Errorf("frame size %d: frames join to %q", frameSize, joined)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// member is a worker that registered with the master
+type member struct {
+	id       string
+	address  string
+	capacity int32
+	version  string
+	// parallelism is the number of goroutines the worker parses a chunk with
+	parallelism int32
+	lastSeen    time.Time
+	// conn is the pooled connection to the address of the worker. It counts
+	// the tasks in flight there, so tasks dispatched before the worker
+	// registered again still count against its limit.
+	conn *pooledConn
+	// client talks to the worker over the pooled connection
+	client pb.MapReduceServiceClient
+	// healthy is false while the connection to the worker is failing or the
+	// worker reports through its health service that it is not serving
+	healthy bool
+}
+
+// membership keeps the table of live workers. Workers are added when they
+// call RegisterWorker and evicted when they miss heartbeats for longer than
+// the timeout.
+type membership struct {
+	mu      sync.Mutex
+	members map[string]*member
+	// conns is the connection pool, keyed by worker address, and dialOpts
+	// the credentials its connections are opened with
+	conns    map[string]*pooledConn
+	dialOpts []grpc.DialOption
+	order    []string // worker IDs in registration order, used for round robin
+	next     int
+	nextID   int
+	// epoch makes worker IDs unique across master restarts, so a worker
+	// still holding an ID from a previous master cannot match a new worker
+	epoch    string
+	interval time.Duration
+	timeout  time.Duration
+	// maxInFlight caps the tasks dispatched to one worker at once; when zero
+	// the capacity or parallelism reported by the worker is used
+	maxInFlight int
+	// changed is closed and replaced every time a worker joins or leaves, or
+	// a dispatch slot is freed
+	changed chan struct{}
+}
+
+func newMembership(interval, timeout time.Duration, maxInFlight int, dialOpts ...grpc.DialOption) *membership {
+	return &membership{
+		members:     make(map[string]*member),
+		conns:       make(map[string]*pooledConn),
+		dialOpts:    dialOpts,
+		interval:    interval,
+		timeout:     timeout,
+		maxInFlight: maxInFlight,
+		changed:     make(chan struct{}),
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+	}
+}
+
+// register adds a worker to the table and returns its ID. A worker that
+// registers again with an address that is already known replaces the old
+// entry and keeps its pooled connection.
+func (m *membership) register(address string, capacity, parallelism int32, version string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	client, pc, err := m.connect(address)
+	if err != nil {
+		return "", err
+	}
+	for id, w := range m.members {
+		if w.address == address {
+			m.remove(id)
+		}
+	}
+	m.nextID++
+	id := fmt.Sprintf("worker-%s-%d", m.epoch, m.nextID)
+	m.members[id] = &member{
+		id:          id,
+		address:     address,
+		capacity:    capacity,
+		version:     version,
+		parallelism: parallelism,
+		lastSeen:    time.Now(),
+		conn:        pc,
+		client:      client,
+		healthy:     pc.healthy(),
+	}
+	m.order = append(m.order, id)
+	// wake up anyone waiting for workers to join
+	m.notify()
+	return id, nil
+}
+
+// notify wakes up everyone waiting on m.changed. The caller must hold m.mu.
+func (m *membership) notify() {
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
+
+// heartbeat refreshes the last seen time of a worker. It returns false if
+// the worker is not (or no longer) a member.
+func (m *membership) heartbeat(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.members[id]
+	if !ok {
+		return false
+	}
+	w.lastSeen = time.Now()
+	return true
+}
+
+// remove deletes a worker from the table. The caller must hold m.mu.
+func (m *membership) remove(id string) {
+	delete(m.members, id)
+	for i, oid := range m.order {
+		if oid == id {
+			m.order = append(m.order[:i], m.order[i+1:]...)
+			break
+		}
+	}
+	// wake up tasks waiting for a slot so they notice if no worker is left
+	m.notify()
+}
+
+// evictExpired removes every worker that has not sent a heartbeat within
+// the timeout.
+func (m *membership) evictExpired() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for id, w := range m.members {
+		if now.Sub(w.lastSeen) > m.timeout {
+			log.Printf("[MASTER] Evicting worker %s (%s): no heartbeat for %v", id, w.address, now.Sub(w.lastSeen).Round(time.Millisecond))
+			m.remove(id)
+			m.disconnect(w.address)
+		}
+	}
+}
+
+// run evicts expired workers until the context is cancelled
+func (m *membership) run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.evictExpired()
+		}
+	}
+}
+
+// limit returns how many tasks may be in flight on a worker at once: the
+// -max-inflight-per-worker cap if set, else the capacity the worker reports,
+// else one task per goroutine the worker parses with
+func (m *membership) limit(w *member) int {
+	if m.maxInFlight > 0 {
+		return m.maxInFlight
+	}
+	if w.capacity > 0 {
+		return int(w.capacity)
+	}
+	return max(int(w.parallelism), 1)
+}
+
+// acquire reserves a dispatch slot on the next healthy worker in round robin
+// order, preferring workers that are not in the exclude set. It blocks while
+// every worker is at its in-flight limit and fails right away if there are
+// no healthy workers. The returned function frees the slot.
+func (m *membership) acquire(ctx context.Context, exclude map[string]bool) (*member, func(), error) {
+	for {
+		m.mu.Lock()
+		if m.healthyCount() == 0 {
+			m.mu.Unlock()
+			return nil, nil, fmt.Errorf("no healthy workers (%d live)", len(m.order))
+		}
+		w := m.free(exclude)
+		if w == nil {
+			// every worker that has not failed the task is full, fall back
+			// to the others rather than waiting on them
+			w = m.free(nil)
+		}
+		if w != nil {
+			pc := w.conn
+			pc.inFlight++
+			inFlightTasks.Set(float64(pc.inFlight), w.address)
+			m.mu.Unlock()
+			release := func() {
+				m.mu.Lock()
+				pc.inFlight--
+				// the series is gone once the connection is closed
+				if m.conns[w.address] == pc {
+					inFlightTasks.Set(float64(pc.inFlight), w.address)
+				}
+				m.notify()
+				m.mu.Unlock()
+			}
+			return w, release, nil
+		}
+		changed := m.changed
+		m.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// healthyCount returns the number of healthy workers. The caller must hold m.mu.
+func (m *membership) healthyCount() int {
+	n := 0
+	for _, w := range m.members {
+		if w.healthy {
+			n++
+		}
+	}
+	return n
+}
+
+// free returns the next healthy worker in round robin order that has a free
+// slot and is not excluded, or nil. The caller must hold m.mu.
+func (m *membership) free(exclude map[string]bool) *member {
+	for i := 0; i < len(m.order); i++ {
+		idx := (m.next + i) % len(m.order)
+		w := m.members[m.order[idx]]
+		if w.healthy && !exclude[w.address] && w.conn.inFlight < m.limit(w) {
+			m.next = idx + 1
+			return w
+		}
+	}
+	return nil
+}
+
+// size returns the number of live workers
+func (m *membership) size() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.members)
+}
+
+// waitFor blocks until at least n workers are live or the context is done
+func (m *membership) waitFor(ctx context.Context, n int) error {
+	for {
+		m.mu.Lock()
+		count, changed := len(m.members), m.changed
+		m.mu.Unlock()
+		if count >= n {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for %d workers, have %d: %w", n, count, ctx.Err())
+		case <-changed:
+		}
+	}
+}
+
+// masterServer implements the membership RPCs of pb.MapReduceServiceServer
+type masterServer struct {
+	pb.UnimplementedMapReduceServiceServer
+	members *membership
+}
+
+// RegisterWorker adds the calling worker to the membership table
+func (s *masterServer) RegisterWorker(ctx context.Context, req *pb.RegisterRequest) (*pb.RegisterResponse, error) {
+	address := advertisedAddress(ctx, req.Address)
+	id, err := s.members.register(address, req.Capacity, req.Parallelism, req.Version)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "register %s: %v", address, err)
+	}
+	log.Printf("[MASTER] Registered %s at %s (capacity %d, parallelism %d, version %s)", id, address, req.Capacity, req.Parallelism, req.Version)
+	return &pb.RegisterResponse{
+		WorkerId:            id,
+		HeartbeatIntervalMs: s.members.interval.Milliseconds(),
+	}, nil
+}
+
+// Heartbeat keeps the calling worker alive in the membership table
+func (s *masterServer) Heartbeat(ctx context.Context, req *pb.HeartbeatRequest) (*pb.HeartbeatResponse, error) {
+	return &pb.HeartbeatResponse{Known: s.members.heartbeat(req.WorkerId)}, nil
+}
+
+// advertisedAddress fills in the host of a worker address such as ":50051"
+// with the IP the registration came from.
+func advertisedAddress(ctx context.Context, address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || host != "" {
+		return address
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return address
+	}
+	peerHost, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return address
+	}
+	return net.JoinHostPort(peerHost, port)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestLimit(t *testing.T) {
+	for _, c := range []struct {
+		maxInFlight           int
+		capacity, parallelism int32
+		want                  int
+	}{
+		{0, 3, 8, 3},
+		{0, 0, 8, 8},
+		{0, 0, 0, 1},
+		{2, 3, 8, 2},
+	} {
+		m := newMembership(time.Second, time.Second, c.maxInFlight)
+		w := &member{capacity: c.capacity, parallelism: c.parallelism}
+		if got := m.limit(w); got != c.want {
+			t.Errorf("limit with cap %d, capacity %d, parallelism %d = %d, want %d",
+				c.maxInFlight, c.capacity, c.parallelism, got, c.want)
+		}
+	}
+}
+
+func TestReregisterKeepsInFlight(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	go server.Serve(lis)
+	defer server.Stop()
+
+	m := newMembership(time.Second, time.Second, 0, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	defer m.close()
+	address := lis.Addr().String()
+	if _, err := m.register(address, 1, 1, "test"); err != nil {
+		t.Fatal(err)
+	}
+	_, release, err := m.acquire(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the worker restarts and registers again while its task is running
+	if _, err := m.register(address, 1, 1, "test"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, _, err := m.acquire(ctx, nil); err != context.DeadlineExceeded {
+		t.Fatalf("acquire over the limit of the address = %v, want it to wait", err)
+	}
+	release()
+	if _, _, err := m.acquire(context.Background(), nil); err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"time"
+
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/metrics"
+	"google.golang.org/grpc/status"
+)
+
+// registry holds the metrics of the master, served on -metrics-listen
+var registry = metrics.NewRegistry()
+
+var (
+	chunksSubmitted = registry.NewCounter("loganalyzer_master_chunks_submitted_total",
+		"Chunks handed to the scheduler.")
+	bytesSubmitted = registry.NewCounter("loganalyzer_master_bytes_submitted_total",
+		"Bytes of the chunks handed to the scheduler, sent to the workers or read by them from shared storage.")
+	linesCollected = registry.NewCounter("loganalyzer_master_lines_total",
+		"Lines of the collected chunks by outcome: read, matched, rejected, skipped or filtered.", "outcome")
+	taskAttempts = registry.NewCounter("loganalyzer_master_task_attempts_total",
+		"Attempts of map and reduce tasks by result: success or failure.", "result")
+	taskRetries = registry.NewCounter("loganalyzer_master_task_retries_total",
+		"Failed task attempts that were retried.")
+	tasksLost = registry.NewCounter("loganalyzer_master_tasks_lost_total",
+		"Tasks that failed every attempt.")
+	taskDuration = registry.NewHistogram("loganalyzer_master_task_attempt_duration_seconds",
+		"Duration of task attempts on a worker, including sending the data.", metrics.DefaultBuckets)
+	grpcErrors = registry.NewCounter("loganalyzer_master_grpc_errors_total",
+		"Failed calls to workers by gRPC status code.", "code")
+	inFlightTasks = registry.NewGauge("loganalyzer_master_inflight_tasks",
+		"Tasks dispatched to each worker and not finished yet.", "worker")
+)
+
+// observeAttempt records the outcome of one task attempt that ran on a
+// worker
+func observeAttempt(started time.Time, err error) {
+	taskDuration.Observe(time.Since(started).Seconds())
+	if err == nil {
+		taskAttempts.Inc("success")
+		return
+	}
+	taskAttempts.Inc("failure")
+	if s, ok := status.FromError(err); ok {
+		grpcErrors.Inc(s.Code().String())
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/ddsketch"
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/query"
+)
+
+// numericStats merges the numeric summaries the workers return for each group
+type numericStats struct {
+	mu       sync.Mutex
+	field    string
+	accuracy float64
+	sketches map[string]*ddsketch.Sketch
+}
+
+func newNumericStats(field string, accuracy float64) *numericStats {
+	return &numericStats{
+		field:    field,
+		accuracy: accuracy,
+		sketches: make(map[string]*ddsketch.Sketch),
+	}
+}
+
+// load checks and decodes the summaries of one chunk without merging them,
+// so a malformed response can be rejected before any of its results count
+func (n *numericStats) load(summaries []*pb.NumericSummary) (map[string]*ddsketch.Sketch, error) {
+	loaded := make(map[string]*ddsketch.Sketch, len(summaries))
+	for _, ns := range summaries {
+		s, err := ddsketch.FromState(n.accuracy, ddsketch.State{
+			Count:    ns.Count,
+			Sum:      ns.Sum,
+			Min:      ns.Min,
+			Max:      ns.Max,
+			Zero:     ns.ZeroCount,
+			Positive: ns.Positive,
+			Negative: ns.Negative,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("numeric summary for key %q: %w", ns.Key, err)
+		}
+		loaded[ns.Key] = s
+	}
+	return loaded, nil
+}
+
+// merge adds loaded summaries
+func (n *numericStats) merge(loaded map[string]*ddsketch.Sketch) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for k, s := range loaded {
+		if mine, ok := n.sketches[k]; ok {
+			mine.Merge(s)
+		} else {
+			n.sketches[k] = s
+		}
+	}
+}
+
+// parsePercentiles parses a list such as "50,95,99" or "99.9"
+func parsePercentiles(spec string) ([]float64, error) {
+	var percentiles []float64
+	for _, f := range strings.Split(spec, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		p, err := strconv.ParseFloat(f, 64)
+		if err != nil || p < 0 || p > 100 {
+			return nil, fmt.Errorf("invalid percentile %q: want a number between 0 and 100", f)
+		}
+		percentiles = append(percentiles, p)
+	}
+	return percentiles, nil
+}
+
+// logNumeric prints the statistics of the given keys, or of every group when
+// keys is nil, sorted by key
+func logNumeric(groupBy query.GroupBy, n *numericStats, keys []string, percentiles []float64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if keys == nil {
+		for k := range n.sketches {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+	}
+	log.Printf("[MASTER] %s by %s (DDSketch, percentiles within %v%%):", n.field, groupBy, 100*n.accuracy)
+	for _, k := range keys {
+		s, ok := n.sketches[k]
+		if !ok {
+			continue
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "count=%d sum=%s min=%s max=%s mean=%s",
+			s.Count(), formatValue(s.Sum()), formatValue(s.Min()), formatValue(s.Max()), formatValue(s.Mean()))
+		for _, p := range percentiles {
+			fmt.Fprintf(&b, " p%s=%s", strconv.FormatFloat(p, 'f', -1, 64), formatValue(s.Quantile(p/100)))
+		}
+		log.Printf("[MASTER]   %s: %s", k, b.String())
+	}
+}
+
+// formatValue prints whole numbers without decimals and others to three
+// decimals, which is finer than the latencies and sizes found in logs
+func formatValue(x float64) string {
+	if x == math.Trunc(x) && math.Abs(x) < 1e15 {
+		return strconv.FormatFloat(x, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(x, 'f', 3, 64)
+}
+
+// cells returns the statistics of a group as output cells named after the
+// field, ex. request_time_p95. A group without numeric values gets empty cells.
+func (n *numericStats) cells(key string, percentiles []float64) []cell {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	s := n.sketches[key]
+	cells := make([]cell, 0, 5+len(percentiles))
+	add := func(name string, value func() any) {
+		var v any
+		if s != nil {
+			v = value()
+		}
+		cells = append(cells, cell{n.field + "_" + name, v})
+	}
+	add("count", func() any { return s.Count() })
+	add("sum", func() any { return s.Sum() })
+	add("min", func() any { return s.Min() })
+	add("max", func() any { return s.Max() })
+	add("mean", func() any { return s.Mean() })
+	for _, p := range percentiles {
+		add("p"+strconv.FormatFloat(p, 'f', -1, 64), func() any { return s.Quantile(p / 100) })
+	}
+	return cells
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/query"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/topk"
+)
+
+// Output formats of -output-format
+const (
+	outputJSON  = "json"
+	outputCSV   = "csv"
+	outputTable = "table"
+)
+
+// outputFormat returns the -output-format to use, guessing it from the
+// extension of the output file when it is not given
+func outputFormat(format, path string) (string, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json":
+			return outputJSON, nil
+		case ".csv":
+			return outputCSV, nil
+		}
+		return outputTable, nil
+	}
+	switch format {
+	case outputJSON, outputCSV, outputTable:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown output format %q (want %s, %s or %s)", format, outputJSON, outputCSV, outputTable)
+}
+
+// summary describes a finished job
+type summary struct {
+	Files         []string `json:"files"`
+	Format        string   `json:"format"`
+	Workers       int      `json:"workers"`
+	Chunks        int      `json:"chunks"`
+	LinesRead     int64    `json:"lines_read"`
+	LinesMatched  int64    `json:"lines_matched"`
+	LinesRejected int64    `json:"lines_rejected"`
+	LinesSkipped  int64    `json:"lines_skipped"`
+	LinesFiltered int64    `json:"lines_filtered"`
+	RejectRatio   float64  `json:"reject_ratio"`
+	// RejectedSample is a random sample of the rejected lines
+	RejectedSample []rejectedLine `json:"rejected_sample"`
+	Duration       float64        `json:"duration_seconds"`
+}
+
+// rejectedLine is a sampled line the log format could not parse
+type rejectedLine struct {
+	File    string `json:"file"`
+	ChunkID string `json:"chunk_id"`
+	Offset  int64  `json:"offset"`
+	Line    string `json:"line"`
+}
+
+func newSummary(files []string, j *job, workers int, duration time.Duration) summary {
+	lines := j.lineTotals()
+	rejected := []rejectedLine{}
+	for _, r := range j.rejectedLines() {
+		rejected = append(rejected, rejectedLine{File: r.Source, ChunkID: r.ChunkId, Offset: r.Offset, Line: r.Line})
+	}
+	return summary{
+		Files:          files,
+		Format:         j.formatNames(),
+		Workers:        workers,
+		Chunks:         j.chunks,
+		LinesRead:      lines.lines,
+		LinesMatched:   lines.matched,
+		LinesRejected:  lines.rejected,
+		LinesSkipped:   lines.skipped,
+		LinesFiltered:  lines.filtered,
+		RejectRatio:    lines.rejectRatio(),
+		RejectedSample: rejected,
+		Duration:       duration.Seconds(),
+	}
+}
+
+// fields returns the summary as name, value pairs in display order
+func (s summary) fields() [][2]string {
+	files := strconv.Itoa(len(s.Files))
+	if len(s.Files) == 1 {
+		files = s.Files[0]
+	}
+	return [][2]string{
+		{"files", files},
+		{"format", s.Format},
+		{"workers", strconv.Itoa(s.Workers)},
+		{"chunks", strconv.Itoa(s.Chunks)},
+		{"lines read", strconv.FormatInt(s.LinesRead, 10)},
+		{"lines matched", strconv.FormatInt(s.LinesMatched, 10)},
+		{"lines rejected", strconv.FormatInt(s.LinesRejected, 10)},
+		{"lines skipped", strconv.FormatInt(s.LinesSkipped, 10)},
+		{"lines filtered", strconv.FormatInt(s.LinesFiltered, 10)},
+		{"reject ratio", formatRatio(s.RejectRatio)},
+		{"duration", (time.Duration(s.Duration * float64(time.Second))).Round(time.Millisecond).String()},
+	}
+}
+
+// logSummary prints the summary of a job
+func logSummary(s summary) {
+	var b strings.Builder
+	for i, f := range s.fields() {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s %s", f[0], f[1])
+	}
+	log.Printf("[MASTER] Job summary: %s", b.String())
+}
+
+// report holds the results of a job in the order they are written
+type report struct {
+	groupBy query.GroupBy
+	bucket  *query.Bucket
+	// approximate is set when counts come from a sketch and carry an error
+	approximate bool
+	counters    []topk.Counter
+	distinct    *distinctCounts
+	numeric     *numericStats
+	percentiles []float64
+	summary     summary
+}
+
+// cell is a named, typed value of a result row. Values are int64, uint64,
+// float64, string or nil when a group has no value.
+type cell struct {
+	name  string
+	value any
+}
+
+// row is a result with its cells in column order
+type row []cell
+
+// MarshalJSON writes a row as an object whose keys keep the column order
+func (r row) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, c := range r {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, err := json.Marshal(c.name)
+		if err != nil {
+			return nil, err
+		}
+		value := c.value
+		if x, ok := value.(float64); ok && (math.IsNaN(x) || math.IsInf(x, 0)) {
+			value = nil
+		}
+		v, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// rows breaks every result key into its bucket and group-by values and adds
+// the aggregates the query asked for
+func (r *report) rows() []row {
+	var distinct map[string]uint64
+	if r.distinct != nil {
+		distinct = r.distinct.estimates(counterKeys(r.counters))
+	}
+	rows := make([]row, 0, len(r.counters))
+	for _, c := range r.counters {
+		var cells row
+		key := c.Key
+		if r.bucket != nil {
+			var start string
+			start, key = query.SplitKey(key)
+			cells = append(cells, cell{"bucket", start})
+		}
+		// a value that contains the separator ends up in the last field
+		values := strings.SplitN(key, query.KeySeparator, len(r.groupBy))
+		for i, name := range r.groupBy {
+			value := ""
+			if i < len(values) {
+				value = values[i]
+			}
+			cells = append(cells, cell{name, value})
+		}
+		cells = append(cells, cell{"count", c.Count})
+		if r.approximate {
+			cells = append(cells, cell{"count_error", c.Error})
+		}
+		if r.distinct != nil {
+			var v any
+			if d, ok := distinct[c.Key]; ok {
+				v = d
+			}
+			cells = append(cells, cell{"distinct_" + r.distinct.field, v})
+		}
+		if r.numeric != nil {
+			cells = append(cells, r.numeric.cells(c.Key, r.percentiles)...)
+		}
+		rows = append(rows, cells)
+	}
+	return rows
+}
+
+// write writes the report in the given format
+func (r *report) write(w io.Writer, format string) error {
+	rows := r.rows()
+	switch format {
+	case outputJSON:
+		out := struct {
+			Summary summary `json:"summary"`
+			Results []row   `json:"results"`
+		}{r.summary, rows}
+		if out.Results == nil {
+			out.Results = []row{}
+		}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	case outputCSV:
+		// CSV holds only the results so it can be loaded as is; the summary
+		// is in the log
+		cw := csv.NewWriter(w)
+		if err := cw.Write(r.header()); err != nil {
+			return err
+		}
+		for _, cells := range rows {
+			if err := cw.Write(formatCells(cells)); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(r.header(), "\t"))
+	for _, cells := range rows {
+		fmt.Fprintln(tw, strings.Join(formatCells(cells), "\t"))
+	}
+	fmt.Fprintln(tw)
+	for _, f := range r.summary.fields() {
+		fmt.Fprintf(tw, "%s:\t%s\n", f[0], f[1])
+	}
+	return tw.Flush()
+}
+
+// header returns the column names of the rows
+func (r *report) header() []string {
+	// build a row for a key without values to get the column names
+	probe := &report{groupBy: r.groupBy, bucket: r.bucket, approximate: r.approximate,
+		distinct: r.distinct, numeric: r.numeric, percentiles: r.percentiles,
+		counters: []topk.Counter{{}}}
+	var names []string
+	for _, c := range probe.rows()[0] {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// formatCells prints the values of a row for CSV and table output
+func formatCells(cells row) []string {
+	values := make([]string, len(cells))
+	for i, c := range cells {
+		switch v := c.value.(type) {
+		case nil:
+		case string:
+			values[i] = v
+		case int64:
+			values[i] = strconv.FormatInt(v, 10)
+		case uint64:
+			values[i] = strconv.FormatUint(v, 10)
+		case float64:
+			if !math.IsNaN(v) && !math.IsInf(v, 0) {
+				values[i] = formatValue(v)
+			}
+		default:
+			values[i] = fmt.Sprint(v)
+		}
+	}
+	return values
+}
+
+// writeReport writes the report to path, or to stdout when path is "-"
+func writeReport(r *report, path, format string) error {
+	if path == "-" {
+		return r.write(os.Stdout, format)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := r.write(f, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/query"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/topk"
+)
+
+func TestReportWrite(t *testing.T) {
+	hour := &query.Bucket{Size: time.Hour, Location: time.UTC}
+	for _, c := range []struct {
+		name string
+		r    *report
+		// want is the output of each format; table only the results
+		csv, json, table string
+	}{
+		{
+			name: "quoting",
+			r: &report{groupBy: query.GroupBy{"method", "path"}, counters: []topk.Counter{
+				{Key: "GET|/a,b", Count: 3},
+				{Key: `POST|/say "hi"`, Count: 2},
+			}},
+			csv:  "method,path,count\nGET,\"/a,b\",3\nPOST,\"/say \"\"hi\"\"\",2\n",
+			json: `[{"method":"GET","path":"/a,b","count":3},{"method":"POST","path":"/say \"hi\"","count":2}]`,
+			table: "method  path       count\n" +
+				"GET     /a,b       3\n" +
+				"POST    /say \"hi\"  2\n",
+		},
+		{
+			name: "separator in the last value",
+			r: &report{groupBy: query.GroupBy{"method", "path"}, counters: []topk.Counter{
+				{Key: "GET|/a|b", Count: 1},
+			}},
+			csv:   "method,path,count\nGET,/a|b,1\n",
+			json:  `[{"method":"GET","path":"/a|b","count":1}]`,
+			table: "method  path  count\nGET     /a|b  1\n",
+		},
+		{
+			name: "bucket first",
+			r: &report{groupBy: query.GroupBy{"status"}, bucket: hour, counters: []topk.Counter{
+				{Key: "2000-10-10T20:00:00Z|200", Count: 5},
+				{Key: "2000-10-10T21:00:00Z|404", Count: 1},
+			}},
+			csv:  "bucket,status,count\n2000-10-10T20:00:00Z,200,5\n2000-10-10T21:00:00Z,404,1\n",
+			json: `[{"bucket":"2000-10-10T20:00:00Z","status":"200","count":5},{"bucket":"2000-10-10T21:00:00Z","status":"404","count":1}]`,
+			table: "bucket                status  count\n" +
+				"2000-10-10T20:00:00Z  200     5\n" +
+				"2000-10-10T21:00:00Z  404     1\n",
+		},
+		{
+			name: "count error",
+			r: &report{groupBy: query.GroupBy{"ip"}, approximate: true, counters: []topk.Counter{
+				{Key: "10.0.0.1", Count: 7, Error: 2},
+			}},
+			csv:   "ip,count,count_error\n10.0.0.1,7,2\n",
+			json:  `[{"ip":"10.0.0.1","count":7,"count_error":2}]`,
+			table: "ip        count  count_error\n10.0.0.1  7      2\n",
+		},
+		{
+			name:  "empty",
+			r:     &report{groupBy: query.GroupBy{"status"}},
+			csv:   "status,count\n",
+			json:  `[]`,
+			table: "status  count\n",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := c.r.write(&b, outputCSV); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != c.csv {
+				t.Errorf("csv:\n%s\nwant:\n%s", b.String(), c.csv)
+			}
+
+			b.Reset()
+			if err := c.r.write(&b, outputJSON); err != nil {
+				t.Fatal(err)
+			}
+			var out struct {
+				Summary *summary        `json:"summary"`
+				Results json.RawMessage `json:"results"`
+			}
+			if err := json.Unmarshal(b.Bytes(), &out); err != nil {
+				t.Fatal(err)
+			}
+			var results bytes.Buffer
+			json.Compact(&results, out.Results)
+			if out.Summary == nil || results.String() != c.json {
+				t.Errorf("json results %s, want %s", results.String(), c.json)
+			}
+
+			b.Reset()
+			if err := c.r.write(&b, outputTable); err != nil {
+				t.Fatal(err)
+			}
+			// the results end at the blank line before the summary
+			table, summary, _ := strings.Cut(b.String(), "\n\n")
+			if table+"\n" != c.table {
+				t.Errorf("table:\n%s\nwant:\n%s", table, c.table)
+			}
+			if !strings.Contains(summary, "lines read:") {
+				t.Errorf("table has no summary:\n%s", summary)
+			}
+		})
+	}
+}
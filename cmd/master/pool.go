@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// The master keeps one long-lived connection per worker address in
+// membership.conns. Connections are opened when a worker registers, reused
+// by every task and job, kept when a worker registers again from the same
+// address, and closed when the worker is evicted. Their connectivity state
+// and the status the worker reports through the gRPC health service drive
+// the healthy flag of the member.
+
+// healthRetry is how long to wait before watching the health of a worker
+// again after the watch failed
+const healthRetry = time.Second
+
+// pooledConn is a connection to a worker and what is known of its health
+type pooledConn struct {
+	conn *grpc.ClientConn
+	// connected is false while the connection is in transient failure
+	connected bool
+	// serving is false while the worker reports it is not serving, ex. when
+	// it is draining
+	serving bool
+	// inFlight is the number of tasks dispatched to the address and not
+	// finished yet
+	inFlight int
+}
+
+func (pc *pooledConn) healthy() bool {
+	return pc.connected && pc.serving
+}
+
+// newWorkerConn opens a connection to a worker with the given credentials
+func newWorkerConn(address string, dialOpts []grpc.DialOption) (*grpc.ClientConn, error) {
+	opts := append([]grpc.DialOption{
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(1024 * 1024 * 1024)),
+	}, dialOpts...)
+	conn, err := grpc.NewClient(address, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("dial worker %s: %w", address, err)
+	}
+	// start connecting right away so state changes show up before the first task
+	conn.Connect()
+	return conn, nil
+}
+
+// connect returns a client for the pooled connection to address, opening the
+// connection if there is none. The caller must hold m.mu.
+func (m *membership) connect(address string) (pb.MapReduceServiceClient, *pooledConn, error) {
+	pc, ok := m.conns[address]
+	if ok {
+		// the worker is back, so do not wait for the reconnect backoff
+		pc.conn.ResetConnectBackoff()
+	} else {
+		conn, err := newWorkerConn(address, m.dialOpts)
+		if err != nil {
+			return nil, nil, err
+		}
+		pc = &pooledConn{conn: conn, connected: true, serving: true}
+		m.conns[address] = pc
+		go m.watch(address, conn)
+		go m.watchHealth(address, conn)
+	}
+	return pb.NewMapReduceServiceClient(pc.conn), pc, nil
+}
+
+// disconnect closes the pooled connection to address. The caller must hold m.mu.
+func (m *membership) disconnect(address string) {
+	if pc, ok := m.conns[address]; ok {
+		delete(m.conns, address)
+		pc.conn.Close()
+		inFlightTasks.Delete(address)
+	}
+}
+
+// setHealth applies change to the pooled connection to address and updates
+// the healthy flag of the workers at that address. reason describes the
+// change in the log.
+func (m *membership) setHealth(address, reason string, change func(pc *pooledConn)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pc, ok := m.conns[address]
+	if !ok {
+		return
+	}
+	change(pc)
+	healthy := pc.healthy()
+	for _, w := range m.members {
+		if w.address == address && w.healthy != healthy {
+			w.healthy = healthy
+			log.Printf("[MASTER] Worker %s (%s) %s, healthy=%v", w.id, address, reason, healthy)
+		}
+	}
+	m.notify()
+}
+
+// watch follows the connectivity state of a pooled connection until it is
+// closed. A connection in transient failure marks the worker at that address
+// unhealthy so no task is dispatched to it; it becomes healthy again once
+// the connection recovers.
+func (m *membership) watch(address string, conn *grpc.ClientConn) {
+	state := conn.GetState()
+	for conn.WaitForStateChange(context.Background(), state) {
+		state = conn.GetState()
+		if state == connectivity.Shutdown {
+			return
+		}
+		connected := state != connectivity.TransientFailure
+		m.setHealth(address, fmt.Sprintf("connection is %s", state), func(pc *pooledConn) {
+			pc.connected = connected
+		})
+	}
+}
+
+// watchHealth follows the serving status the worker at address reports
+// through the gRPC health service until the connection is closed. A worker
+// without the health service is taken as serving.
+func (m *membership) watchHealth(address string, conn *grpc.ClientConn) {
+	client := healthpb.NewHealthClient(conn)
+	for {
+		err := m.followHealth(client, address)
+		if status.Code(err) == codes.Unimplemented {
+			log.Printf("[MASTER] Worker %s has no health service, relying on its connection state", address)
+			m.setHealth(address, "has no health service", func(pc *pooledConn) {
+				pc.serving = true
+			})
+			return
+		}
+		if conn.GetState() == connectivity.Shutdown {
+			return
+		}
+		time.Sleep(healthRetry)
+	}
+}
+
+// followHealth watches the serving status of the worker until the stream
+// fails
+func (m *membership) followHealth(client healthpb.HealthClient, address string) error {
+	req := &healthpb.HealthCheckRequest{Service: pb.MapReduceService_ServiceDesc.ServiceName}
+	stream, err := client.Watch(context.Background(), req, grpc.WaitForReady(true))
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		serving := resp.GetStatus() == healthpb.HealthCheckResponse_SERVING
+		m.setHealth(address, fmt.Sprintf("reports %s", resp.GetStatus()), func(pc *pooledConn) {
+			pc.serving = serving
+		})
+	}
+}
+
+// close shuts down every pooled connection
+func (m *membership) close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for address := range m.conns {
+		m.disconnect(address)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+)
+
+// rejectRatio returns the share of the lines the log format tried to parse
+// that it rejected. Skipped lines, such as W3C directives, are not counted.
+func (c lineCounts) rejectRatio() float64 {
+	parsed := c.lines - c.skipped
+	if parsed <= 0 {
+		return 0
+	}
+	return float64(c.rejected) / float64(parsed)
+}
+
+// logQuality prints how much of the input the log format understood and the
+// sampled rejected lines
+func logQuality(format string, lines lineCounts, rejected []*pb.RejectedLine) {
+	log.Printf("[MASTER] Data quality: %d of %d lines rejected by the %s format (%s), %d skipped, %d filtered",
+		lines.rejected, lines.lines, format, formatRatio(lines.rejectRatio()), lines.skipped, lines.filtered)
+	if len(rejected) == 0 {
+		return
+	}
+	log.Printf("[MASTER] Sample of %d rejected lines:", len(rejected))
+	for _, r := range rejected {
+		log.Printf("[MASTER]   %s %s offset %d: %q", r.Source, r.ChunkId, r.Offset, r.Line)
+	}
+}
+
+// checkRejectRatio fails when more of the lines were rejected than allowed
+func checkRejectRatio(lines lineCounts, maxRatio float64) error {
+	if ratio := lines.rejectRatio(); ratio > maxRatio {
+		return fmt.Errorf("%s of lines rejected, more than -max-reject-ratio %s", formatRatio(ratio), formatRatio(maxRatio))
+	}
+	return nil
+}
+
+// formatRatio prints a ratio as a percentage
+func formatRatio(ratio float64) string {
+	return fmt.Sprintf("%.3g%%", 100*ratio)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/query"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/topk"
+)
+
+// logSeries prints results whose keys start with a time bucket as a time
+// series: one line per bucket in time order, with the count of every group
+// in that bucket. Lines without a valid time are reported last under "-".
+func logSeries(groupBy query.GroupBy, bucket *query.Bucket, results []*pb.AggregatedResult) {
+	series := make(map[string]map[string]int64)
+	for _, r := range results {
+		start, key := query.SplitKey(r.Key)
+		if series[start] == nil {
+			series[start] = make(map[string]int64)
+		}
+		series[start][key] += r.TotalCount
+	}
+	starts := make([]string, 0, len(series))
+	for start := range series {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		return compareBuckets(starts[i], starts[j]) < 0
+	})
+	log.Printf("[MASTER] Time series of %s per %v (%s):", groupBy, bucket.Size, bucket.Location)
+	for _, start := range starts {
+		counts := series[start]
+		keys := make([]string, 0, len(counts))
+		for k := range counts {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var b strings.Builder
+		for _, k := range keys {
+			fmt.Fprintf(&b, " %s=%d", k, counts[k])
+		}
+		if start == "" {
+			start = "-"
+		}
+		log.Printf("[MASTER]   %s%s", start, b.String())
+	}
+}
+
+// compareBuckets orders bucket starts by time, with the bucket of lines
+// without a valid time last
+func compareBuckets(a, b string) int {
+	ta, aerr := time.Parse(query.BucketLayout, a)
+	tb, berr := time.Parse(query.BucketLayout, b)
+	switch {
+	case aerr != nil && berr != nil:
+		return strings.Compare(a, b)
+	case aerr != nil:
+		return 1
+	case berr != nil:
+		return -1
+	}
+	return ta.Compare(tb)
+}
+
+// sortSeries sorts results with bucketed keys by bucket, then by group
+func sortSeries(counters []topk.Counter) {
+	sort.SliceStable(counters, func(i, j int) bool {
+		bi, ki := query.SplitKey(counters[i].Key)
+		bj, kj := query.SplitKey(counters[j].Key)
+		if c := compareBuckets(bi, bj); c != 0 {
+			return c < 0
+		}
+		return ki < kj
+	})
+}
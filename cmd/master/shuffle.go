@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxReduceBatch bounds the encoded size of one ReduceRequest of a reduce
+// stream, well under the message size limit of the workers
+const maxReduceBatch = 16 << 20
+
+// shuffle collects map output grouped by the reduce partition each key was
+// hashed to by the worker, ready to be handed to ProcessReduceStream. The
+// partial results are kept as they arrived: summing them is the job of the
+// worker that reduces the partition.
+type shuffle struct {
+	mu         sync.Mutex
+	partitions [][]*pb.PartialResult
+}
+
+func newShuffle(numPartitions int) *shuffle {
+	return &shuffle{partitions: make([][]*pb.PartialResult, numPartitions)}
+}
+
+// add files the partial results of one chunk under their partitions
+func (s *shuffle) add(partials []*pb.PartialResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, pr := range partials {
+		if pr.Partition < 0 || int(pr.Partition) >= len(s.partitions) {
+			return fmt.Errorf("partial result for key %q has partition %d, want [0, %d)", pr.Key, pr.Partition, len(s.partitions))
+		}
+	}
+	for _, pr := range partials {
+		s.partitions[pr.Partition] = append(s.partitions[pr.Partition], pr)
+	}
+	return nil
+}
+
+// size returns the number of partial results collected so far
+func (s *shuffle) size() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, p := range s.partitions {
+		n += len(p)
+	}
+	return n
+}
+
+// batches cuts the partial results of a partition into batches that each
+// encode to at most maxReduceBatch bytes, unless a single partial result is
+// larger
+func batches(partials []*pb.PartialResult) [][]*pb.PartialResult {
+	var (
+		all  [][]*pb.PartialResult
+		size int
+	)
+	start := 0
+	for i, pr := range partials {
+		// the partial result plus its tag and length in the request
+		n := proto.Size(pr) + 6
+		if i > start && size+n > maxReduceBatch {
+			all = append(all, partials[start:i])
+			start, size = i, 0
+		}
+		size += n
+	}
+	if start < len(partials) {
+		all = append(all, partials[start:])
+	}
+	return all
+}
+
+// reduce streams every non-empty partition to a worker through the
+// scheduler and concatenates the final results. Keys never span partitions,
+// so no further merging is needed. It returns the names of lost partitions.
+func (s *shuffle) reduce(ctx context.Context, sched *scheduler, job string) ([]*pb.AggregatedResult, []string) {
+	var (
+		mu      sync.Mutex
+		results []*pb.AggregatedResult
+		reduced int
+	)
+	for p, partials := range s.partitions {
+		if len(partials) == 0 {
+			continue
+		}
+		reduced++
+		partition, partials := int32(p), partials
+		sched.submit(ctx, partitionName(p), func(ctx context.Context, w *member) error {
+			aggregated, err := sendReduce(ctx, job, partition, partials, w)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			results = append(results, aggregated...)
+			mu.Unlock()
+			return nil
+		}, nil)
+	}
+	lost := sched.wait()
+	log.Printf("[MASTER] Reduced %d partitions into %d results", reduced-len(lost), len(results))
+	return results, lost
+}
+
+// sendReduce streams one partition to a worker in batches of at most
+// maxReduceBatch bytes and returns its aggregated results
+func sendReduce(ctx context.Context, job string, partition int32, partials []*pb.PartialResult, w *member) ([]*pb.AggregatedResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := w.client.ProcessReduceStream(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("open reduce stream on %s: %w", w.address, err)
+	}
+	for _, batch := range batches(partials) {
+		err := stream.Send(&pb.ReduceRequest{PartialResults: batch, Partition: partition, JobId: job})
+		if err != nil {
+			return nil, fmt.Errorf("send reduce batch to %s: %w", w.address, streamErr(stream, err))
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return nil, fmt.Errorf("process reduce on %s: %w", w.address, err)
+	}
+	return resp.Results, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+)
+
+// recordingReducer records the reduce batches it is streamed and answers
+// with the number of partial results in each batch
+type recordingReducer struct {
+	pb.UnimplementedMapReduceServiceServer
+	batches []*pb.ReduceRequest
+}
+
+func (r *recordingReducer) ProcessReduceStream(stream grpc.ClientStreamingServer[pb.ReduceRequest, pb.ReduceResponse]) error {
+	resp := &pb.ReduceResponse{}
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(resp)
+		}
+		if err != nil {
+			return err
+		}
+		r.batches = append(r.batches, req)
+		resp.Results = append(resp.Results, &pb.AggregatedResult{Key: "batch", TotalCount: int64(len(req.PartialResults))})
+	}
+}
+
+func TestShuffleKeepsPartials(t *testing.T) {
+	s := newShuffle(2)
+	for chunk := 0; chunk < 3; chunk++ {
+		err := s.add([]*pb.PartialResult{
+			{Key: "200", Count: 5, Partition: 0},
+			{Key: "404", Count: 1, Partition: 1},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the workers sum the counts of a key, not the master
+	if s.size() != 6 || len(s.partitions[0]) != 3 {
+		t.Fatalf("size() = %d with %d partials in partition 0, want 6 and 3", s.size(), len(s.partitions[0]))
+	}
+	if err := s.add([]*pb.PartialResult{{Key: "500", Count: 1, Partition: 2}}); err == nil {
+		t.Error("add accepted a partition out of range")
+	}
+}
+
+func TestSendReduceStreamsUncombinedBatches(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reducer := &recordingReducer{}
+	// workers accept messages of up to 64MB
+	server := grpc.NewServer(grpc.MaxRecvMsgSize(64 << 20))
+	pb.RegisterMapReduceServiceServer(server, reducer)
+	go server.Serve(lis)
+	defer server.Stop()
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	w := &member{address: lis.Addr().String(), client: pb.NewMapReduceServiceClient(conn)}
+
+	// 48MB of partial results for the same few keys, as many chunks send
+	key := strings.Repeat("k", 1<<16)
+	var partials []*pb.PartialResult
+	for i := 0; i < 768; i++ {
+		partials = append(partials, &pb.PartialResult{Key: key + string(rune('a'+i%3)), Count: 1, Partition: 1})
+	}
+	results, err := sendReduce(context.Background(), "job-1", 1, partials, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reducer.batches) < 3 {
+		t.Fatalf("sent %d batches, want the partition split", len(reducer.batches))
+	}
+	for _, batch := range reducer.batches {
+		if size := proto.Size(batch); size > maxReduceBatch {
+			t.Errorf("batch of %d bytes exceeds %d", size, maxReduceBatch)
+		}
+	}
+	sent := int64(0)
+	for _, r := range results {
+		sent += r.TotalCount
+	}
+	if sent != int64(len(partials)) {
+		t.Errorf("sent %d partial results, want all %d uncombined", sent, len(partials))
+	}
+	if reducer.batches[0].Partition != 1 {
+		t.Errorf("first batch is for partition %d, want 1", reducer.batches[0].Partition)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// taskState is the lifecycle of a single task:
+//
+//	pending -> in-flight -> done
+//	              |
+//	              +-> pending (retry on another worker) -> ... -> failed
+type taskState int
+
+const (
+	taskPending taskState = iota
+	taskInFlight
+	taskDone
+	taskFailed
+)
+
+func (s taskState) String() string {
+	switch s {
+	case taskPending:
+		return "pending"
+	case taskInFlight:
+		return "in-flight"
+	case taskDone:
+		return "done"
+	case taskFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("taskState(%d)", int(s))
+}
+
+// taskFunc runs a task on a worker
+type taskFunc func(ctx context.Context, w *member) error
+
+// task is one unit of work (a map chunk or a reduce partition) and its
+// processing state
+type task struct {
+	name     string
+	do       taskFunc
+	state    taskState
+	attempts int
+	// tried holds the workers that already failed this task so retries go elsewhere
+	tried map[string]bool
+	err   error
+	// onFinish is called once the task is done or failed, if set
+	onFinish func()
+}
+
+// chunkName returns the chunk ID sent to workers in MapRequest.ChunkId
+func chunkName(id int) string {
+	return fmt.Sprintf("chunk-%d", id)
+}
+
+// partitionName returns the task name of a reduce partition
+func partitionName(partition int) string {
+	return fmt.Sprintf("partition-%d", partition)
+}
+
+// scheduler runs tasks against the live workers. A task that fails is
+// retried with exponential backoff on a worker that has not failed it yet,
+// until it succeeds or runs out of attempts.
+type scheduler struct {
+	members     *membership
+	maxAttempts int
+	backoff     time.Duration
+	timeout     time.Duration
+
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	tasks []*task
+}
+
+func newScheduler(members *membership, maxAttempts int, backoff, timeout time.Duration) *scheduler {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &scheduler{
+		members:     members,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		timeout:     timeout,
+	}
+}
+
+// submit queues a task and starts processing it in the background. onFinish,
+// if not nil, is called once the task is done or failed.
+func (s *scheduler) submit(ctx context.Context, name string, do taskFunc, onFinish func()) {
+	t := &task{name: name, do: do, onFinish: onFinish, state: taskPending, tried: make(map[string]bool)}
+	s.mu.Lock()
+	s.tasks = append(s.tasks, t)
+	s.mu.Unlock()
+	s.wg.Add(1)
+	go s.run(ctx, t)
+}
+
+// run drives a task until it is done or has exhausted its attempts
+func (s *scheduler) run(ctx context.Context, t *task) {
+	defer s.wg.Done()
+	for {
+		t.attempts++
+		// wait for a free slot, preferring workers that have not failed the task
+		w, release, err := s.members.acquire(ctx, t.tried)
+		workerAddr := ""
+		if err == nil {
+			workerAddr = w.address
+			s.setState(t, taskInFlight)
+			t.tried[workerAddr] = true
+			started := time.Now()
+			err = s.attempt(ctx, t, w)
+			observeAttempt(started, err)
+			release()
+			if err == nil {
+				s.finish(t, taskDone, nil)
+				return
+			}
+		}
+		log.Printf("[MASTER] Task %s attempt %d/%d failed on %q: %v", t.name, t.attempts, s.maxAttempts, workerAddr, err)
+		if t.attempts >= s.maxAttempts || ctx.Err() != nil {
+			tasksLost.Inc()
+			s.finish(t, taskFailed, err)
+			return
+		}
+		taskRetries.Inc()
+		s.setState(t, taskPending)
+		// back off exponentially before the next attempt
+		select {
+		case <-ctx.Done():
+		case <-time.After(s.backoff << (t.attempts - 1)):
+		}
+	}
+}
+
+// attempt runs the task on a single worker, bounded by the task timeout
+func (s *scheduler) attempt(ctx context.Context, t *task, w *member) error {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	return t.do(ctx, w)
+}
+
+func (s *scheduler) setState(t *task, state taskState) {
+	s.mu.Lock()
+	t.state = state
+	s.mu.Unlock()
+}
+
+// finish moves a task into a final state and drops its payload
+func (s *scheduler) finish(t *task, state taskState, err error) {
+	s.mu.Lock()
+	t.state = state
+	t.err = err
+	t.do = nil
+	s.mu.Unlock()
+	if t.onFinish != nil {
+		t.onFinish()
+	}
+}
+
+// wait blocks until every submitted task is done or failed and returns the
+// names of the tasks that were lost. The scheduler can be reused afterwards.
+func (s *scheduler) wait() []string {
+	s.wg.Wait()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var lost []string
+	for _, t := range s.tasks {
+		if t.state == taskFailed {
+			lost = append(lost, t.name)
+		}
+	}
+	s.tasks = nil
+	return lost
+}
+
+// drain drops the tasks that are done or failed without waiting for the
+// others and returns the names of the failed ones. Long-running jobs call it
+// so finished tasks do not pile up.
+func (s *scheduler) drain() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var (
+		lost    []string
+		running []*task
+	)
+	for _, t := range s.tasks {
+		switch t.state {
+		case taskDone:
+		case taskFailed:
+			lost = append(lost, t.name)
+		default:
+			running = append(running, t)
+		}
+	}
+	s.tasks = running
+	return lost
+}
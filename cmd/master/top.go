@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/query"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/topk"
+)
+
+// Modes of -top-mode
+const (
+	// topModeSketch keeps a Space-Saving sketch per chunk on the workers and
+	// merges the sketches on the master. Counts are approximate but memory
+	// stays bounded however many distinct keys there are.
+	topModeSketch = "sketch"
+	// topModeExact counts every key through the shuffle and reduce phases and
+	// ranks the final counts. Use it when the number of distinct keys is small.
+	topModeExact = "exact"
+)
+
+// minSketchCapacity is the smallest default sketch size for -top
+const minSketchCapacity = 1000
+
+// sketchCapacity returns the number of counters used to find the top n keys
+// when -top-capacity is not set. Keeping many more counters than n keeps the
+// error of the reported counts small.
+func sketchCapacity(n int) int {
+	return max(10*n, minSketchCapacity)
+}
+
+// heavyHitters merges the sketches the workers return for each chunk
+type heavyHitters struct {
+	mu     sync.Mutex
+	sketch *topk.Sketch
+	chunks int
+}
+
+func newHeavyHitters(capacity int) *heavyHitters {
+	return &heavyHitters{sketch: topk.New(capacity)}
+}
+
+// add merges the sketch of one chunk
+func (h *heavyHitters) add(heavyHitters []*pb.HeavyHitter) {
+	counters := make([]topk.Counter, len(heavyHitters))
+	for i, hh := range heavyHitters {
+		counters[i] = topk.Counter{Key: hh.Key, Count: hh.Count, Error: hh.Error}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sketch.Merge(topk.FromCounters(h.sketch.Capacity(), counters))
+	h.chunks++
+}
+
+// top returns the n keys with the largest estimated counts, and the most any
+// other key may have occurred
+func (h *heavyHitters) top(n int) ([]topk.Counter, int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	counters := h.sketch.Top(n + 1)
+	if len(counters) > n {
+		return counters[:n], counters[n].Count
+	}
+	return counters, h.sketch.Floor()
+}
+
+// exactTop ranks the final results of the reduce phase
+func exactTop(results []*pb.AggregatedResult, n int) []topk.Counter {
+	counters := make([]topk.Counter, len(results))
+	for i, r := range results {
+		counters[i] = topk.Counter{Key: r.Key, Count: r.TotalCount}
+	}
+	return topk.Top(counters, n)
+}
+
+// counterKeys returns the keys of counters
+func counterKeys(counters []topk.Counter) []string {
+	keys := make([]string, len(counters))
+	for i, c := range counters {
+		keys[i] = c.Key
+	}
+	return keys
+}
+
+// logTop prints a ranked list of keys. rest is the most any key left out of
+// the list may have occurred; a key whose lowest possible count is above it
+// is marked as guaranteed to belong in the list whatever the error.
+func logTop(groupBy query.GroupBy, mode string, counters []topk.Counter, rest int64) {
+	log.Printf("[MASTER] Top %d by %s (%s):", len(counters), groupBy, mode)
+	for i, c := range counters {
+		bound := ""
+		if c.Error > 0 {
+			bound = fmt.Sprintf(" (at least %d)", c.Count-c.Error)
+			if c.Count-c.Error > rest {
+				bound += ", guaranteed"
+			}
+		}
+		log.Printf("[MASTER] %3d. %s %d%s", i+1, c.Key, c.Count, bound)
+	}
+}
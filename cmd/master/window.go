@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/query"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/topk"
+)
+
+// windowSlots is how many slots a window is divided into. Counts expire a
+// slot at a time, so the reported span is accurate to span/windowSlots.
+const windowSlots = 60
+
+// untimed marks the partial results of lines without a time
+const untimed = math.MinInt64
+
+// window keeps rolling counts of the batches collected in follow mode: the
+// counts of the last span of the log, and of everything since the start when
+// span is 0. The span is measured in the lines' own times and ends at the
+// latest one seen, so a replayed log or a late batch is counted where its
+// lines belong. Workers prefix each key with the slot of its line (see
+// slotSeconds); lines without a time are counted in the latest slot.
+// Batches are merged here directly, without a reduce phase.
+type window struct {
+	mu       sync.Mutex
+	span     time.Duration
+	slotSize time.Duration
+	// slots holds the counts of each slot by its start in Unix seconds
+	slots map[int64]map[string]int64
+	// latest is the start of the latest slot a line was counted in
+	latest int64
+}
+
+func newWindow(span time.Duration) *window {
+	w := &window{span: span, slots: make(map[int64]map[string]int64)}
+	if span > 0 {
+		// log times are in whole seconds
+		w.slotSize = max((span / windowSlots).Truncate(time.Second), time.Second)
+	}
+	return w
+}
+
+// slotSeconds is the slot size workers prefix keys with, 0 when the window
+// counts everything and needs no slots
+func (w *window) slotSeconds() int64 {
+	return int64(w.slotSize / time.Second)
+}
+
+// add counts the partial results of one batch
+func (w *window) add(partials []*pb.PartialResult) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	slots := make([]int64, len(partials))
+	keys := make([]string, len(partials))
+	for i, pr := range partials {
+		keys[i] = pr.Key
+		if w.span == 0 {
+			continue
+		}
+		slot, key, ok := strings.Cut(pr.Key, query.KeySeparator)
+		if !ok {
+			return fmt.Errorf("result key %q has no window slot", pr.Key)
+		}
+		keys[i], slots[i] = key, untimed
+		if slot == "" {
+			continue
+		}
+		start, err := strconv.ParseInt(slot, 10, 64)
+		if err != nil {
+			return fmt.Errorf("result key %q has an invalid window slot: %w", pr.Key, err)
+		}
+		slots[i] = start
+		w.latest = max(w.latest, start)
+	}
+	for i, pr := range partials {
+		slot := slots[i]
+		if slot == untimed {
+			slot = w.latest
+		}
+		counts := w.slots[slot]
+		if counts == nil {
+			counts = make(map[string]int64)
+			w.slots[slot] = counts
+		}
+		counts[keys[i]] += pr.Count
+	}
+	w.expire()
+	return nil
+}
+
+// expire drops the slots that ended before the window, which ends with the
+// latest slot
+func (w *window) expire() {
+	if w.span == 0 {
+		return
+	}
+	from := w.latest + int64(w.slotSize/time.Second) - int64(w.span/time.Second)
+	for start := range w.slots {
+		if start+int64(w.slotSize/time.Second) <= from {
+			delete(w.slots, start)
+		}
+	}
+}
+
+// counters returns the counts of the window, largest first
+func (w *window) counters() []topk.Counter {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	totals := make(map[string]int64)
+	for _, counts := range w.slots {
+		for k, v := range counts {
+			totals[k] += v
+		}
+	}
+	counters := make([]topk.Counter, 0, len(totals))
+	for k, v := range totals {
+		counters = append(counters, topk.Counter{Key: k, Count: v})
+	}
+	return topk.Top(counters, len(counters))
+}
+
+// String describes the span of the window, ex. "last 5m0s"
+func (w *window) String() string {
+	if w.span == 0 {
+		return "since start"
+	}
+	return "last " + w.span.String()
+}
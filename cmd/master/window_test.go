@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+)
+
+func windowCounts(w *window) string {
+	counts := make(map[string]int64)
+	for _, c := range w.counters() {
+		counts[c.Key] = c.Count
+	}
+	return fmt.Sprint(counts)
+}
+
+func TestWindowCountsByLogTime(t *testing.T) {
+	w := newWindow(time.Minute)
+	if got := w.slotSeconds(); got != 1 {
+		t.Fatalf("slots of %d seconds, want 1", got)
+	}
+	steps := []struct {
+		keys []string
+		want map[string]int64
+	}{
+		// lines without a time go in the latest slot
+		{[]string{"1000|200", "1030|200", "1030|200", "|404"}, map[string]int64{"200": 3, "404": 1}},
+		// the window ends at the latest time seen, not when batches arrive
+		{[]string{"1040|500"}, map[string]int64{"200": 3, "404": 1, "500": 1}},
+		// a minute after 1000 its slot expires
+		{[]string{"1060|200"}, map[string]int64{"200": 3, "404": 1, "500": 1}},
+		{[]string{"1061|200"}, map[string]int64{"200": 4, "404": 1, "500": 1}},
+		// lines older than the window are not counted
+		{[]string{"900|503"}, map[string]int64{"200": 4, "404": 1, "500": 1}},
+		{[]string{"1100|200"}, map[string]int64{"200": 3}},
+	}
+	for i, step := range steps {
+		partials := make([]*pb.PartialResult, len(step.keys))
+		for k, key := range step.keys {
+			partials[k] = &pb.PartialResult{Key: key, Count: 1}
+		}
+		if err := w.add(partials); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := windowCounts(w), fmt.Sprint(step.want); got != want {
+			t.Errorf("step %d: counts %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestWindowSinceStart(t *testing.T) {
+	w := newWindow(0)
+	if got := w.slotSeconds(); got != 0 {
+		t.Fatalf("slots of %d seconds, want none", got)
+	}
+	w.add([]*pb.PartialResult{{Key: "200", Count: 2}, {Key: "404", Count: 1}})
+	w.add([]*pb.PartialResult{{Key: "200", Count: 1}})
+	if got, want := windowCounts(w), fmt.Sprint(map[string]int64{"200": 3, "404": 1}); got != want {
+		t.Errorf("counts %s, want %s", got, want)
+	}
+}
+
+func TestWindowRejectsKeyWithoutSlot(t *testing.T) {
+	w := newWindow(time.Minute)
+	if err := w.add([]*pb.PartialResult{{Key: "200", Count: 1}}); err == nil {
+		t.Error("key without a slot was counted")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// drainer reports the readiness of the worker through the gRPC health
+// service and lets the calls in progress finish when it shuts down. While
+// draining, the health status is NOT_SERVING, so the master stops
+// dispatching to the worker, and new calls are refused.
+type drainer struct {
+	health *health.Server
+
+	mu       sync.Mutex
+	draining bool
+	// active counts the map and reduce calls in progress
+	active sync.WaitGroup
+	// closing is cancelled once draining is over to end the health watches,
+	// which would otherwise keep the server from stopping
+	closing context.Context
+	close   context.CancelFunc
+}
+
+func newDrainer() *drainer {
+	d := &drainer{health: health.NewServer()}
+	d.closing, d.close = context.WithCancel(context.Background())
+	d.health.SetServingStatus(pb.MapReduceService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	return d
+}
+
+// isInfrastructure reports whether a method belongs to the health or
+// reflection services rather than the work of the worker
+func isInfrastructure(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/grpc.")
+}
+
+// begin registers a call in progress, or refuses it while draining
+func (d *drainer) begin() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return status.Error(codes.Unavailable, "worker is draining")
+	}
+	d.active.Add(1)
+	return nil
+}
+
+func (d *drainer) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if isInfrastructure(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	if err := d.begin(); err != nil {
+		return nil, err
+	}
+	defer d.active.Done()
+	return handler(ctx, req)
+}
+
+func (d *drainer) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if isInfrastructure(info.FullMethod) {
+		ctx, cancel := context.WithCancel(ss.Context())
+		defer cancel()
+		stop := context.AfterFunc(d.closing, cancel)
+		defer stop()
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+	if err := d.begin(); err != nil {
+		return err
+	}
+	defer d.active.Done()
+	return handler(srv, ss)
+}
+
+// serverStream replaces the context of a stream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// drain marks the worker NOT_SERVING, refuses new calls and waits up to
+// timeout for the calls in progress, then ends the health watches. It
+// reports whether the calls all finished.
+func (d *drainer) drain(timeout time.Duration) bool {
+	d.mu.Lock()
+	d.draining = true
+	d.mu.Unlock()
+	d.health.Shutdown()
+	done := make(chan struct{})
+	go func() {
+		d.active.Wait()
+		close(done)
+	}()
+	defer d.close()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
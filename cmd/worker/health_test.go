@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// blockingWorker holds every map call until release is closed
+type blockingWorker struct {
+	pb.UnimplementedMapReduceServiceServer
+	started chan struct{}
+	release chan struct{}
+}
+
+func (w *blockingWorker) ProcessMap(ctx context.Context, req *pb.MapRequest) (*pb.MapResponse, error) {
+	w.started <- struct{}{}
+	<-w.release
+	return &pb.MapResponse{}, nil
+}
+
+func servingStatus(t *testing.T, client healthpb.HealthClient) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: pb.MapReduceService_ServiceDesc.ServiceName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.Status
+}
+
+func TestDrain(t *testing.T) {
+	d := newDrainer()
+	worker := &blockingWorker{started: make(chan struct{}), release: make(chan struct{})}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(d.unary), grpc.ChainStreamInterceptor(d.stream))
+	pb.RegisterMapReduceServiceServer(server, worker)
+	healthpb.RegisterHealthServer(server, d.health)
+	go server.Serve(lis)
+	defer server.Stop()
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := pb.NewMapReduceServiceClient(conn)
+	health := healthpb.NewHealthClient(conn)
+
+	if got := servingStatus(t, health); got != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("status %v before draining, want SERVING", got)
+	}
+	watch, err := health.Watch(context.Background(), &healthpb.HealthCheckRequest{Service: pb.MapReduceService_ServiceDesc.ServiceName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := watch.Recv(); err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("watch sent %v, %v, want SERVING", resp, err)
+	}
+
+	inFlight := make(chan error, 1)
+	go func() {
+		_, err := client.ProcessMap(context.Background(), &pb.MapRequest{})
+		inFlight <- err
+	}()
+	<-worker.started
+	drained := make(chan bool, 1)
+	go func() { drained <- d.drain(time.Minute) }()
+
+	if resp, err := watch.Recv(); err != nil || resp.Status != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("watch sent %v, %v while draining, want NOT_SERVING", resp, err)
+	}
+	if got := servingStatus(t, health); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("status %v while draining, want NOT_SERVING", got)
+	}
+	if _, err := client.ProcessMap(context.Background(), &pb.MapRequest{}); status.Code(err) != codes.Unavailable {
+		t.Errorf("new call while draining returned %v, want Unavailable", err)
+	}
+	stream, err := client.ProcessMapStream(context.Background())
+	if err == nil {
+		_, err = stream.CloseAndRecv()
+	}
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("new stream while draining returned %v, want Unavailable", err)
+	}
+
+	select {
+	case <-drained:
+		t.Fatal("drain returned before the call in progress finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(worker.release)
+	if err := <-inFlight; err != nil {
+		t.Errorf("call in progress failed: %v", err)
+	}
+	if !<-drained {
+		t.Error("drain reported calls still in progress")
+	}
+	// the health watches end so the server can stop
+	if _, err := watch.Recv(); err == nil {
+		t.Error("health watch still open after draining")
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	d := newDrainer()
+	if err := d.begin(); err != nil {
+		t.Fatal(err)
+	}
+	defer d.active.Done()
+	start := time.Now()
+	if d.drain(20 * time.Millisecond) {
+		t.Error("drain reported the stuck call finished")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("drain took %v, want about the timeout", elapsed)
+	}
+	if err := d.begin(); status.Code(err) != codes.Unavailable {
+		t.Errorf("begin after draining returned %v, want Unavailable", err)
+	}
+}
@@ -1,92 +1,271 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"fmt"
+	"flag"
 	"log"
 	"net"
-	"regexp"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/auth"
 	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/tlsconfig"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
 // workerServer implements the pb.MapReduceServiceServer
 type workerServer struct {
 	pb.UnimplementedMapReduceServiceServer
+	// sharedRoot restricts shared-storage reads to files below it, if set
+	sharedRoot string
+	// parallelism is the number of goroutines that parse a chunk
+	parallelism int
 }
 
 // ProcessMap handles the Map phase of the job
 func (s *workerServer) ProcessMap(ctx context.Context, req *pb.MapRequest) (*pb.MapResponse, error){
 	log.Printf("[WORKER] Recieved Map request for chunk %s", req.ChunkId)
-	// Split the log data by new line
-	lines := bytes.Split(req.LogData, []byte("\n"))
-	// Create a map to store the counts of each status code
-	counts := make(map[string]int64)
-	// Iterate over each line and extract the status code
-	for _, lineBytes := range lines {
-		if len(lineBytes) == 0 {
-			continue
+	if err := auth.CheckJob(ctx, req.JobId); err != nil {
+		return nil, err
+	}
+	m, err := newMapper(req, s.parallelism)
+	if err != nil {
+		return nil, err
+	}
+	if req.Path != "" {
+		// Shared-storage mode: read the byte range ourselves
+		path, err := s.sharedPath(req.Path)
+		if err != nil {
+			return nil, err
 		}
-		// Convert the line to a string
-		line := string(lineBytes)
-		// Define a regex to extract the fields
-		logRegex := regexp.MustCompile(`(?P<IP>\S+) \S+ \S+ \[(?P<Date>[^\]]+)] "(?P<Request>[^"]*)" (?P<StatusCode>\d{3}) (?P<Size>\d+) "(?P<Referrer>[^"]*)" "(?P<UserAgent>[^"]*)"`)
-		// Find the matches
-		matches := logRegex.FindStringSubmatch(line)
-		if matches == nil {
-			fmt.Println("No match found")
-			continue
+		if req.Compression != "" {
+			err = m.readCompressedRange(path, req.Compression, req.Offset, req.RawOffset, req.RawLength, req.LineStart)
+		} else {
+			err = m.readRange(path, req.Offset, req.Length)
 		}
-		// Extract the fields
-		fields := []string {
-			matches[1], // IP address
-			matches[2], // Date
-			matches[3], // Request
-			matches[4], // Status code
-			matches[5], // Response size
-			matches[6], // Referrer
-			matches[7], // User agent
+		if err != nil {
+			return nil, status.Errorf(codes.FailedPrecondition, "read %s [%d, %d): %v", path, req.Offset, req.Offset+req.Length, err)
 		}
-		statusCode := fields[3]
-		counts[statusCode]++
+	} else {
+		m.write(req.LogData)
 	}
+	// Return the partial results
+	resp := m.response()
+	observeMap(m, resp.Stats)
+	if st := resp.Stats; st.Rejected > 0 {
+		log.Printf("[WORKER] Chunk %s: %d of %d lines rejected by the %s format", req.ChunkId, st.Rejected, st.Lines, m.format.Name)
+	}
+	return resp, nil
+}
 
-	// Prepare the partial results
-	var partialResults []*pb.PartialResult
-	for k, v := range counts {
-		partialResults = append(partialResults, &pb.PartialResult{
-			Key: k,
-			Count: v,
-		})
+
+// sharedPath checks that a shared-storage path is below -shared-root once
+// symlinks are followed, and returns it resolved. Without -shared-root the
+// worker opens no files for the master.
+func (s *workerServer) sharedPath(path string) (string, error) {
+	if s.sharedRoot == "" {
+		return "", status.Error(codes.PermissionDenied, "shared-storage reads are disabled, start the worker with -shared-root")
 	}
-	// Return the partial results
-	return &pb.MapResponse{
-		PartialResults: partialResults,
-	}, nil
+	path = filepath.Clean(path)
+	below := func(path string) bool {
+		rel, err := filepath.Rel(s.sharedRoot, path)
+		return err == nil && filepath.IsLocal(rel)
+	}
+	if !below(path) {
+		return "", status.Errorf(codes.PermissionDenied, "path %s is outside the shared root %s", path, s.sharedRoot)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", status.Errorf(codes.FailedPrecondition, "resolve %s: %v", path, err)
+	}
+	if !below(resolved) {
+		return "", status.Errorf(codes.PermissionDenied, "path %s links outside the shared root %s", path, s.sharedRoot)
+	}
+	return resolved, nil
 }
 
+// workerName names the worker in the credentials it signs, after the
+// address it advertises (IDs cannot contain dots)
+func workerName(address string) string {
+	return "worker-" + strings.NewReplacer(".", "-", ":", "-").Replace(strings.Trim(address, "[]"))
+}
 
 func main() {
-	// Create a listener on port 50051
-	lis, err := net.Listen("tcp", ":50051")
+	// Parse the command line flags
+	listenAddr := flag.String("listen", ":50051", "Address to serve the worker gRPC API on")
+	advertiseAddr := flag.String("advertise", "", "Address the master should dial (defaults to -listen)")
+	masterAddr := flag.String("master", "127.0.0.1:50050", "Address of the master to register with")
+	capacity := flag.Int("capacity", 0, "Number of chunks this worker is willing to process at once (0 lets the master send one per -parallelism goroutine)")
+	parallelism := flag.Int("parallelism", runtime.GOMAXPROCS(0), "Number of goroutines that parse each chunk")
+	sharedRoot := flag.String("shared-root", "", "Directory the master may have the worker read shared-storage files from (shared-storage reads are refused without it)")
+	var tlsConfig tlsconfig.Config
+	tlsConfig.RegisterFlags(flag.CommandLine)
+	metricsAddr := flag.String("metrics-listen", "", "Serve Prometheus metrics at /metrics on this address (ex. :9091)")
+	enableReflection := flag.Bool("reflection", false, "Register the gRPC reflection service, for tools such as grpcurl")
+	drainTimeout := flag.Duration("drain-timeout", 30*time.Second, "On SIGTERM or Ctrl-C, how long to wait for the calls in progress before stopping")
+	authTokens := flag.String("auth-tokens", "", "Only accept calls with a bearer token listed in this file, one 'name token' per line")
+	authJobKey := flag.String("auth-job-key-file", "", "Only accept calls with a job credential signed with the key in this file (also see -auth-tokens), and sign credentials with it when registering")
+	authToken := flag.String("auth-token-file", "", "Send the bearer token in this file to the master when registering")
+	authTTL := flag.Duration("auth-credential-ttl", time.Minute, "How long a credential signed with -auth-job-key-file is valid after it is sent")
+	flag.Parse()
+	if *advertiseAddr == "" {
+		*advertiseAddr = *listenAddr
+	}
+	if *sharedRoot != "" {
+		// resolve the root the way requested paths are resolved
+		root, err := filepath.Abs(*sharedRoot)
+		if err == nil {
+			root, err = filepath.EvalSymlinks(root)
+		}
+		if err != nil {
+			log.Fatalf("Invalid -shared-root: %v", err)
+		}
+		*sharedRoot = root
+	}
+
+	// Serve the master and register with it using the same TLS settings
+	tlsConfig.Logf = func(format string, args ...any) {
+		log.Printf("[WORKER] TLS: "+format, args...)
+	}
+	serverCreds, dialCreds, err := tlsConfig.Credentials()
+	if err != nil {
+		log.Fatalf("Invalid TLS settings: %v", err)
+	}
+
+	// Require credentials from the master when tokens or a job key are set
+	var (
+		authn *auth.Interceptor
+		key   []byte
+	)
+	if *authJobKey != "" {
+		if key, err = auth.LoadSecret(*authJobKey); err != nil {
+			log.Fatalf("Invalid -auth-job-key-file: %v", err)
+		}
+	}
+	if *authTokens != "" || key != nil {
+		var tokens []auth.Token
+		if *authTokens != "" {
+			if tokens, err = auth.LoadTokens(*authTokens); err != nil {
+				log.Fatalf("Invalid -auth-tokens: %v", err)
+			}
+		}
+		verifier, err := auth.NewVerifier(tokens, key, auth.AudienceWorker)
+		if err != nil {
+			log.Fatalf("Invalid authentication settings: %v", err)
+		}
+		authn = &auth.Interceptor{Verifier: verifier, Logf: func(format string, args ...any) {
+			log.Printf("[WORKER] "+format, args...)
+		}}
+	}
+	// Authenticate to the master with a token, or else with credentials
+	// signed with the job key
+	regOpts := []grpc.DialOption{dialCreds}
+	switch {
+	case *authToken != "":
+		token, err := auth.LoadSecret(*authToken)
+		if err != nil {
+			log.Fatalf("Invalid -auth-token-file: %v", err)
+		}
+		regOpts = append(regOpts, grpc.WithPerRPCCredentials(auth.NewTokenCredentials(string(token), tlsConfig.Enabled())))
+	case key != nil:
+		if *authTTL <= 0 {
+			log.Fatal("Invalid -auth-credential-ttl: must be positive")
+		}
+		creds, err := auth.NewJobCredentials(key, auth.AudienceMaster, workerName(*advertiseAddr), *authTTL, tlsConfig.Enabled())
+		if err != nil {
+			log.Fatalf("Invalid authentication settings: %v", err)
+		}
+		regOpts = append(regOpts, grpc.WithPerRPCCredentials(creds))
+	}
+	if (authn != nil || len(regOpts) > 1) && !tlsConfig.Enabled() {
+		log.Println("[WORKER] Warning: credentials are sent in cleartext without -tls-cert")
+	}
+
+	// Create a listener on the worker address
+	lis, err := net.Listen("tcp", *listenAddr)
 	if err != nil {
 		log.Fatalf("Failed to listen: %v", err)
 	}
 	// Create a grpc options to allow large messages (64MB)
 	serverOptions := []grpc.ServerOption{
+		serverCreds,
 		grpc.MaxRecvMsgSize(1024 * 1024 * 64),
 		grpc.MaxSendMsgSize(1024 * 1024 * 64),
 	}
+	// Measure every call, including those the authentication rejects, and
+	// track the calls in progress so the worker can drain
+	drain := newDrainer()
+	unary := []grpc.UnaryServerInterceptor{metricsUnary}
+	stream := []grpc.StreamServerInterceptor{metricsStream}
+	if authn != nil {
+		unary = append(unary, authn.Unary)
+		stream = append(stream, authn.Stream)
+	}
+	unary = append(unary, drain.unary)
+	stream = append(stream, drain.stream)
+	serverOptions = append(serverOptions, grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(stream...))
 	// Create a new gRPC server with the options
 	grpcServer := grpc.NewServer(serverOptions...)
-	pb.RegisterMapReduceServiceServer(grpcServer, &workerServer{})
+	pb.RegisterMapReduceServiceServer(grpcServer, &workerServer{sharedRoot: *sharedRoot, parallelism: *parallelism})
+	// Report readiness through the standard health service
+	healthpb.RegisterHealthServer(grpcServer, drain.health)
+	if *enableReflection {
+		reflection.Register(grpcServer)
+	}
+
+	// Announce the worker to the master and keep sending heartbeats
+	reg := &registration{
+		masterAddr:  *masterAddr,
+		address:     *advertiseAddr,
+		capacity:    int32(*capacity),
+		parallelism: int32(*parallelism),
+		dialOpts:    regOpts,
+	}
+	regCtx, stopRegistration := context.WithCancel(context.Background())
+	go reg.run(regCtx)
+
+	// On SIGTERM or Ctrl-C, stop taking work and let the calls in progress
+	// finish before stopping
+	stopCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-stopCtx.Done()
+		log.Printf("[WORKER] Draining: waiting up to %v for the calls in progress", *drainTimeout)
+		stopRegistration()
+		if drain.drain(*drainTimeout) {
+			grpcServer.GracefulStop()
+		} else {
+			log.Println("[WORKER] Calls still in progress after -drain-timeout, stopping anyway")
+			grpcServer.Stop()
+		}
+	}()
+
+	if *metricsAddr != "" {
+		metricsLis, err := net.Listen("tcp", *metricsAddr)
+		if err != nil {
+			log.Fatalf("Failed to listen for metrics: %v", err)
+		}
+		go func() {
+			if err := registry.Serve(metricsLis); err != nil {
+				log.Fatalf("Failed to serve metrics: %v", err)
+			}
+		}()
+		log.Printf("[WORKER] Serving metrics on %s/metrics", *metricsAddr)
+	}
 
-	//Will implement server reflection for debugging
-	//reflection.Register(grpcServer)
-	log.Printf("[WORKER] Starting gRPC server on :50051")
+	log.Printf("[WORKER] Starting gRPC server on %s", *listenAddr)
 	if err := grpcServer.Serve(lis); err != nil {
 		log.Fatalf("Failed to serve: %v", err)
 	}
+	log.Println("[WORKER] Stopped")
 }
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSharedPath(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(dir, "root")
+	os.Mkdir(root, 0o755)
+	os.WriteFile(filepath.Join(root, "access.log"), nil, 0o644)
+	os.WriteFile(filepath.Join(dir, "secret"), nil, 0o600)
+	os.Symlink(filepath.Join(dir, "secret"), filepath.Join(root, "escape"))
+	os.Symlink(filepath.Join(root, "access.log"), filepath.Join(root, "inside"))
+
+	s := &workerServer{sharedRoot: root}
+	for _, c := range []struct {
+		path string
+		code codes.Code
+		want string
+	}{
+		{filepath.Join(root, "access.log"), codes.OK, filepath.Join(root, "access.log")},
+		{filepath.Join(root, "inside"), codes.OK, filepath.Join(root, "access.log")},
+		{filepath.Join(root, "escape"), codes.PermissionDenied, ""},
+		{filepath.Join(root, "..", "secret"), codes.PermissionDenied, ""},
+		{filepath.Join(dir, "secret"), codes.PermissionDenied, ""},
+		{filepath.Join(root, "missing.log"), codes.FailedPrecondition, ""},
+	} {
+		got, err := s.sharedPath(c.path)
+		if status.Code(err) != c.code || got != c.want {
+			t.Errorf("sharedPath(%s) = %q, %v, want %q, %v", c.path, got, err, c.want, c.code)
+		}
+	}
+
+	// without -shared-root the worker reads nothing
+	if _, err := (&workerServer{}).sharedPath(filepath.Join(root, "access.log")); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("sharedPath without a root = %v, want PermissionDenied", err)
+	}
+}
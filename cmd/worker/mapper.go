@@ -0,0 +1,625 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/compressed"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/ddsketch"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/filter"
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/hll"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/logformat"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/query"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/reservoir"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/topk"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// minParallelBytes is the smallest amount of data worth splitting across
+// parser goroutines
+const minParallelBytes = 64 * 1024
+
+// maxSketchCapacity bounds the number of counters a query may ask for
+const maxSketchCapacity = 1 << 20
+
+// maxRejectSample bounds the number of rejected lines a query may sample
+const maxRejectSample = 1000
+
+// maxRejectedLineBytes is how much of a rejected line is sampled
+const maxRejectedLineBytes = 512
+
+// mapper runs the Map phase over the data of one chunk. The data can arrive
+// in several pieces; a line split across pieces is carried over to the next.
+// Each piece is split at line boundaries and parsed by up to parallelism
+// goroutines, whose tallies are merged afterwards.
+type mapper struct {
+	format  *logformat.Format
+	header  string
+	groupBy query.GroupBy
+	// filter chooses the lines that are counted when set
+	filter *filter.Matcher
+	// bucket prefixes every key with the time bucket of the line when set
+	bucket *query.Bucket
+	// location is the timezone the hour and day fields are given in
+	location *time.Location
+	// windowSeconds prefixes every key with the start of the line's window
+	// slot when set
+	windowSeconds int64
+	numPartitions int32
+	parallelism   int
+	// sketchCapacity is the size of the Space-Saving sketch that replaces
+	// the exact counts when the query asks for heavy hitters
+	sketchCapacity int
+	// distinct is the field whose distinct values are counted per group,
+	// with HyperLogLog sketches of the given precision
+	distinct          string
+	distinctPrecision int
+	// aggregate is the numeric field summarised per group, with DDSketches
+	// of the given relative accuracy
+	aggregate         string
+	aggregateAccuracy float64
+	// rejectSample is the number of rejected lines sampled for the master
+	rejectSample int
+	chunkID      string
+	// source is the input file of the chunk, the value of the file field
+	source string
+	// parser is used when the format keeps state between lines (ex. W3C
+	// #Fields directives), which forces the lines to be parsed in order
+	parser logformat.Parser
+	// tally stores the merged results of every piece
+	tally *tally
+	// partial is an incomplete line left over from the previous piece
+	partial []byte
+	// offset is where the next line to process starts in the file
+	offset int64
+	// processed is the number of bytes parsed so far
+	processed int64
+}
+
+// tally holds what one parser goroutine has extracted from its lines
+type tally struct {
+	// counts stores the counts of each key
+	counts map[string]int64
+	// sketch summarises the keys instead of counts when it is set
+	sketch *topk.Sketch
+	// distinct holds the distinct values of each key when the query asks for them
+	distinct          map[string]*hll.Sketch
+	distinctPrecision int
+	// numeric holds the values of the aggregate field of each key when the
+	// query asks for them
+	numeric           map[string]*ddsketch.Sketch
+	aggregateAccuracy float64
+	// stats counts the lines by outcome
+	stats lineStats
+	// rejected samples the lines the format could not parse
+	rejected *reservoir.Sample[*pb.RejectedLine]
+}
+
+// lineStats counts the lines of a chunk by outcome, see pb.MapStats
+type lineStats struct {
+	lines, matched, rejected, skipped, filtered int64
+}
+
+func (s *lineStats) merge(other lineStats) {
+	s.lines += other.lines
+	s.matched += other.matched
+	s.rejected += other.rejected
+	s.skipped += other.skipped
+	s.filtered += other.filtered
+}
+
+func (m *mapper) newTally() *tally {
+	t := &tally{
+		distinctPrecision: m.distinctPrecision,
+		aggregateAccuracy: m.aggregateAccuracy,
+		rejected:          reservoir.New[*pb.RejectedLine](m.rejectSample),
+	}
+	if m.sketchCapacity > 0 {
+		t.sketch = topk.New(m.sketchCapacity)
+	} else {
+		t.counts = make(map[string]int64)
+	}
+	if m.distinct != "" {
+		t.distinct = make(map[string]*hll.Sketch)
+	}
+	if m.aggregate != "" {
+		t.numeric = make(map[string]*ddsketch.Sketch)
+	}
+	return t
+}
+
+// add counts one line with the given key
+func (t *tally) add(key string) {
+	if t.sketch != nil {
+		t.sketch.Add(key, 1)
+		return
+	}
+	t.counts[key]++
+}
+
+// addDistinct records a value of the distinct field under key
+func (t *tally) addDistinct(key, value string) {
+	s, ok := t.distinct[key]
+	if !ok {
+		// the precision was validated by newMapper
+		s, _ = hll.New(t.distinctPrecision)
+		t.distinct[key] = s
+	}
+	s.Add(value)
+}
+
+// addNumeric records a value of the aggregate field under key. Values that
+// are not numbers, such as the "-" size of a response without a body, are
+// left out.
+func (t *tally) addNumeric(key, value string) {
+	x, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(x) || math.IsInf(x, 0) {
+		return
+	}
+	s, ok := t.numeric[key]
+	if !ok {
+		// the accuracy was validated by newMapper
+		s, _ = ddsketch.New(t.aggregateAccuracy)
+		t.numeric[key] = s
+	}
+	s.Add(x)
+}
+
+// merge adds the results of another tally into t
+func (t *tally) merge(other *tally) {
+	t.stats.merge(other.stats)
+	t.rejected.Merge(other.rejected)
+	for k, s := range other.numeric {
+		if mine, ok := t.numeric[k]; ok {
+			mine.Merge(s)
+		} else {
+			t.numeric[k] = s
+		}
+	}
+	for k, s := range other.distinct {
+		if mine, ok := t.distinct[k]; ok {
+			mine.Merge(s)
+		} else {
+			t.distinct[k] = s
+		}
+	}
+	if t.sketch != nil {
+		t.sketch.Merge(other.sketch)
+		return
+	}
+	for k, v := range other.counts {
+		t.counts[k] += v
+	}
+}
+
+// newMapper validates the job settings of a MapRequest and prepares a mapper
+// that parses with up to parallelism goroutines
+func newMapper(req *pb.MapRequest, parallelism int) (*mapper, error) {
+	// Look up the parser for the log format
+	format, err := logformat.Lookup(req.Format)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	// Work out which fields form the result key
+	groupBy := query.GroupBy(req.GetQuery().GetGroupBy())
+	if len(groupBy) == 0 {
+		groupBy = query.DefaultGroupBy
+	}
+	if err := groupBy.Validate(format.HasField); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	sketchCapacity := req.GetQuery().GetSketchCapacity()
+	if sketchCapacity < 0 || sketchCapacity > maxSketchCapacity {
+		return nil, status.Errorf(codes.InvalidArgument, "sketch capacity %d out of range [0, %d]", sketchCapacity, maxSketchCapacity)
+	}
+	distinct := req.GetQuery().GetDistinct()
+	distinctPrecision := int(req.GetQuery().GetDistinctPrecision())
+	if distinct != "" {
+		if !query.HasField(distinct, format.HasField) {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown distinct field %q", distinct)
+		}
+		if _, err := hll.New(distinctPrecision); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	var matcher *filter.Matcher
+	if f := req.GetQuery().GetFilter(); f != nil {
+		matcher, err = filter.New(f, format.HasField)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	location, err := time.LoadLocation(req.GetQuery().GetTimezone())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid timezone %q: %v", req.GetQuery().GetTimezone(), err)
+	}
+	var bucket *query.Bucket
+	if secs := req.GetQuery().GetBucketSeconds(); secs != 0 {
+		bucket, err = query.NewBucket(time.Duration(secs)*time.Second, req.GetQuery().GetTimezone())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	windowSeconds := req.GetQuery().GetWindowSeconds()
+	if windowSeconds < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "window slot of %d seconds is negative", windowSeconds)
+	}
+	rejectSample := req.GetQuery().GetRejectSample()
+	if rejectSample < 0 || rejectSample > maxRejectSample {
+		return nil, status.Errorf(codes.InvalidArgument, "reject sample %d out of range [0, %d]", rejectSample, maxRejectSample)
+	}
+	aggregate := req.GetQuery().GetAggregate()
+	aggregateAccuracy := req.GetQuery().GetAggregateAccuracy()
+	if aggregate != "" {
+		if !query.HasField(aggregate, format.HasField) {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown aggregate field %q", aggregate)
+		}
+		if _, err := ddsketch.New(aggregateAccuracy); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	m := &mapper{
+		format:            format,
+		header:            req.FormatHeader,
+		groupBy:           groupBy,
+		filter:            matcher,
+		bucket:            bucket,
+		location:          location,
+		windowSeconds:     windowSeconds,
+		numPartitions:     req.NumPartitions,
+		parallelism:       max(parallelism, 1),
+		sketchCapacity:    int(sketchCapacity),
+		distinct:          distinct,
+		distinctPrecision: distinctPrecision,
+		aggregate:         aggregate,
+		aggregateAccuracy: aggregateAccuracy,
+		rejectSample:      int(rejectSample),
+		chunkID:           req.ChunkId,
+		source:            req.Source,
+		offset:            req.Offset,
+	}
+	m.tally = m.newTally()
+	if p := format.New(req.FormatHeader); isStateful(p) {
+		m.parser = p
+		m.parallelism = 1
+	}
+	return m, nil
+}
+
+// isStateful reports whether a parser depends on earlier lines of the chunk
+func isStateful(p logformat.Parser) bool {
+	_, ok := p.(logformat.HeaderParser)
+	return ok
+}
+
+// write processes every complete line in data and keeps the incomplete tail
+// for the next call
+func (m *mapper) write(data []byte) {
+	if len(m.partial) > 0 {
+		data = append(m.partial, data...)
+		m.partial = nil
+	}
+	lastNewline := bytes.LastIndexByte(data, '\n')
+	if lastNewline < len(data)-1 {
+		m.partial = append([]byte(nil), data[lastNewline+1:]...)
+		data = data[:lastNewline+1]
+	}
+	m.process(data, m.offset)
+	m.offset += int64(len(data))
+}
+
+// process parses the lines of data, which starts at offset in the file,
+// fanning them out to parser goroutines when there is enough data
+func (m *mapper) process(data []byte, offset int64) {
+	m.processed += int64(len(data))
+	if m.parser != nil {
+		m.parse(m.parser, data, offset, m.tally)
+		return
+	}
+	segments := splitLines(data, m.parallelism, minParallelBytes)
+	if len(segments) == 1 {
+		m.parse(m.format.New(m.header), data, offset, m.tally)
+		return
+	}
+	tallies := make([]*tally, len(segments))
+	var wg sync.WaitGroup
+	for i, segment := range segments {
+		wg.Add(1)
+		go func(offset int64) {
+			defer wg.Done()
+			tallies[i] = m.newTally()
+			m.parse(m.format.New(m.header), segment, offset, tallies[i])
+		}(offset)
+		offset += int64(len(segment))
+	}
+	wg.Wait()
+	// Merge the per-goroutine results
+	for _, t := range tallies {
+		m.tally.merge(t)
+	}
+}
+
+// splitLines cuts data into at most n segments of roughly equal size that
+// end at line boundaries. Segments are at least minSize bytes.
+func splitLines(data []byte, n, minSize int) [][]byte {
+	n = min(n, len(data)/minSize)
+	if n <= 1 {
+		return [][]byte{data}
+	}
+	segments := make([][]byte, 0, n)
+	size := len(data) / n
+	for len(data) > 0 && len(segments) < n-1 {
+		if size >= len(data) {
+			break
+		}
+		end := bytes.IndexByte(data[size:], '\n')
+		if end < 0 {
+			break
+		}
+		end += size + 1
+		segments = append(segments, data[:end])
+		data = data[end:]
+	}
+	if len(data) > 0 {
+		segments = append(segments, data)
+	}
+	return segments
+}
+
+// parse extracts the lines of data, which starts at offset in the file, into t
+func (m *mapper) parse(parser logformat.Parser, data []byte, offset int64, t *tally) {
+	// Split the log data by new line
+	lines := bytes.Split(data, []byte("\n"))
+	// Iterate over each line and extract the key
+	for _, lineBytes := range lines {
+		lineOffset := offset
+		offset += int64(len(lineBytes)) + 1
+		lineBytes = bytes.TrimSuffix(lineBytes, []byte("\r"))
+		if len(lineBytes) == 0 {
+			continue
+		}
+		t.stats.lines++
+		// Convert the line to a string
+		line := string(lineBytes)
+		// Extract the fields
+		record := query.Record{File: m.source, Location: m.location}
+		switch parser.Parse(line, &record) {
+		case logformat.Skipped:
+			t.stats.skipped++
+			continue
+		case logformat.Rejected:
+			t.stats.rejected++
+			t.rejected.Add(&pb.RejectedLine{Offset: lineOffset, Line: sampleLine(lineBytes)})
+			continue
+		}
+		if m.filter != nil && !m.filter.Match(&record) {
+			t.stats.filtered++
+			continue
+		}
+		t.stats.matched++
+		key := m.groupBy.Key(&record)
+		if m.bucket != nil {
+			key = m.bucket.Key(&record) + query.KeySeparator + key
+		}
+		if m.windowSeconds != 0 {
+			key = windowSlot(&record, m.windowSeconds) + query.KeySeparator + key
+		}
+		t.add(key)
+		if t.distinct != nil {
+			t.addDistinct(key, record.Field(m.distinct))
+		}
+		if t.numeric != nil {
+			t.addNumeric(key, record.Field(m.aggregate))
+		}
+	}
+}
+
+// windowSlot returns the start of the record's window slot in Unix seconds,
+// or "" if the record has no valid time
+func windowSlot(r *query.Record, seconds int64) string {
+	t, err := r.Timestamp()
+	if err != nil {
+		return ""
+	}
+	return strconv.FormatInt(t.Truncate(time.Duration(seconds)*time.Second).Unix(), 10)
+}
+
+// sampleLine returns a rejected line as it is sent to the master: cut to
+// maxRejectedLineBytes and valid UTF-8, as protobuf strings must be
+func sampleLine(line []byte) string {
+	if len(line) > maxRejectedLineBytes {
+		line = line[:maxRejectedLineBytes]
+	}
+	return strings.ToValidUTF8(string(line), "\uFFFD")
+}
+
+// response flushes any incomplete last line and returns the partial results
+func (m *mapper) response() *pb.MapResponse {
+	if len(m.partial) > 0 {
+		m.process(m.partial, m.offset)
+		m.offset += int64(len(m.partial))
+		m.partial = nil
+	}
+	st := m.tally.stats
+	stats := &pb.MapStats{
+		Lines:          st.lines,
+		Matched:        st.matched,
+		Rejected:       st.rejected,
+		Skipped:        st.skipped,
+		Filtered:       st.filtered,
+		RejectedSample: m.tally.rejected.Items(),
+	}
+	for _, r := range stats.RejectedSample {
+		r.ChunkId = m.chunkID
+		r.Source = m.source
+	}
+	// Distinct sketches are merged by the master whatever the count mode
+	var distinctSketches []*pb.DistinctSketch
+	for k, s := range m.tally.distinct {
+		distinctSketches = append(distinctSketches, &pb.DistinctSketch{
+			Key:       k,
+			Precision: int32(s.Precision()),
+			Registers: s.Registers(),
+			Sparse:    s.Sparse(),
+		})
+	}
+	// So are numeric summaries
+	var numericSummaries []*pb.NumericSummary
+	for k, s := range m.tally.numeric {
+		st := s.State()
+		numericSummaries = append(numericSummaries, &pb.NumericSummary{
+			Key:       k,
+			Count:     st.Count,
+			Sum:       st.Sum,
+			Min:       st.Min,
+			Max:       st.Max,
+			ZeroCount: st.Zero,
+			Positive:  st.Positive,
+			Negative:  st.Negative,
+		})
+	}
+	// A sketch is sent as is, the master merges it without a reduce phase
+	if m.tally.sketch != nil {
+		var heavyHitters []*pb.HeavyHitter
+		for _, c := range m.tally.sketch.Counters() {
+			heavyHitters = append(heavyHitters, &pb.HeavyHitter{
+				Key:   c.Key,
+				Count: c.Count,
+				Error: c.Error,
+			})
+		}
+		return &pb.MapResponse{
+			HeavyHitters:     heavyHitters,
+			DistinctSketches: distinctSketches,
+			NumericSummaries: numericSummaries,
+			Stats:            stats,
+		}
+	}
+	// Prepare the partial results, tagged with their reduce partition
+	var partialResults []*pb.PartialResult
+	for k, v := range m.tally.counts {
+		partialResults = append(partialResults, &pb.PartialResult{
+			Key:       k,
+			Count:     v,
+			Partition: partition(k, m.numPartitions),
+		})
+	}
+	return &pb.MapResponse{
+		PartialResults:   partialResults,
+		DistinctSketches: distinctSketches,
+		NumericSummaries: numericSummaries,
+		Stats:            stats,
+	}
+}
+
+// readBatchSize is how much of a shared-storage range is read before the
+// lines are processed
+const readBatchSize = 1024 * 1024
+
+// readRange processes the lines of [offset, offset+length) of a file on shared
+// storage. A range that does not start at the beginning of the file skips the
+// partial line it starts in, which belongs to the previous range, and every
+// range finishes the line it ends in.
+func (m *mapper) readRange(path string, offset, length int64) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	// start one byte early so a range that starts exactly at the beginning of
+	// a line does not skip it
+	pos := offset
+	if offset > 0 {
+		pos = offset - 1
+	}
+	if _, err := file.Seek(pos, io.SeekStart); err != nil {
+		return err
+	}
+	reader := bufio.NewReaderSize(file, readBatchSize)
+	if offset > 0 {
+		skipped, err := reader.ReadBytes('\n')
+		pos += int64(len(skipped))
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	m.offset = pos
+	return m.readLines(reader, pos, offset+length)
+}
+
+// readCompressedRange processes the lines of a range of a compressed file
+// on shared storage. The range starts at a member boundary, so it can be
+// decompressed on its own, and covers [rawOffset, rawOffset+rawLength) of the
+// decompressed data. Lines are aligned as in readRange, but in the
+// decompressed data: the range skips the partial line it starts in unless
+// lineStart is set, and finishes the line it ends in from the next members.
+func (m *mapper) readCompressedRange(path, compression string, offset, rawOffset, rawLength int64, lineStart bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	decompressed, err := compressed.NewReader(bufio.NewReaderSize(file, readBatchSize), compression)
+	if err != nil {
+		return err
+	}
+	defer decompressed.Close()
+	reader := bufio.NewReaderSize(decompressed, readBatchSize)
+	pos := rawOffset
+	if !lineStart {
+		skipped, err := reader.ReadBytes('\n')
+		pos += int64(len(skipped))
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	m.offset = pos
+	return m.readLines(reader, pos, rawOffset+rawLength)
+}
+
+// readLines processes every line of reader, which is at pos, that starts
+// before end
+func (m *mapper) readLines(reader *bufio.Reader, pos, end int64) error {
+	batch := make([]byte, 0, readBatchSize)
+	for pos < end {
+		fragment, err := reader.ReadSlice('\n')
+		batch = append(batch, fragment...)
+		pos += int64(len(fragment))
+		// the line is longer than the reader buffer, keep reading it
+		for err == bufio.ErrBufferFull {
+			fragment, err = reader.ReadSlice('\n')
+			batch = append(batch, fragment...)
+			pos += int64(len(fragment))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(batch) >= readBatchSize {
+			m.write(batch)
+			batch = batch[:0]
+		}
+	}
+	m.write(batch)
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+)
+
+// testLog returns n combined log lines of varying length, each with its own
+// path, so that counting by path shows lines that were lost or counted twice
+func testLog(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "10.0.0.%d - - [10/Oct/2000:13:55:36 -0700] \"GET /p%d%s HTTP/1.1\" 200 %d \"-\" \"test\"\n",
+			i%256, i, strings.Repeat("x", i%37), i)
+	}
+	return b.String()
+}
+
+func newTestMapper(t *testing.T, parallelism int) *mapper {
+	t.Helper()
+	m, err := newMapper(&pb.MapRequest{Query: &pb.QuerySpec{GroupBy: []string{"path"}}}, parallelism)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// checkOnce fails unless every one of the n lines of testLog was counted
+// exactly once
+func checkOnce(t *testing.T, counts map[string]int64, n int) {
+	t.Helper()
+	if len(counts) != n {
+		t.Errorf("got %d paths, want %d", len(counts), n)
+	}
+	for path, count := range counts {
+		if count != 1 {
+			t.Errorf("path %s counted %d times", path, count)
+		}
+	}
+}
+
+func TestWriteJoinsSplitLines(t *testing.T) {
+	const n = 200
+	data := testLog(n)
+	for _, frameSize := range []int{1, 7, 64, 1000, len(data)} {
+		m := newTestMapper(t, 1)
+		for rest := data; len(rest) > 0; {
+			frame := rest[:min(frameSize, len(rest))]
+			rest = rest[len(frame):]
+			m.write([]byte(frame))
+		}
+		st := m.response().Stats
+		if st.Lines != n || st.Matched != n {
+			t.Errorf("frame size %d: %d lines, %d matched, want %d", frameSize, st.Lines, st.Matched, n)
+		}
+		checkOnce(t, m.tally.counts, n)
+	}
+}
+
+func TestWriteFlushesLastLine(t *testing.T) {
+	m := newTestMapper(t, 1)
+	m.write([]byte(strings.TrimSuffix(testLog(3), "\n")))
+	if st := m.response().Stats; st.Matched != 3 {
+		t.Errorf("matched %d lines, want 3", st.Matched)
+	}
+}
+
+func TestReadRangeCoversEveryLineOnce(t *testing.T) {
+	const n = 300
+	data := testLog(n)
+	path := filepath.Join(t.TempDir(), "access.log")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	size := int64(len(data))
+	// ranges starting mid-line, on a line start and right after a newline
+	lineLen := int64(strings.IndexByte(data, '\n') + 1)
+	for _, chunkSize := range []int64{97, lineLen - 1, lineLen, lineLen + 1, 4096, size} {
+		counts := make(map[string]int64)
+		for offset := int64(0); offset < size; offset += chunkSize {
+			m := newTestMapper(t, 2)
+			if err := m.readRange(path, offset, min(chunkSize, size-offset)); err != nil {
+				t.Fatal(err)
+			}
+			m.response()
+			for k, v := range m.tally.counts {
+				counts[k] += v
+			}
+		}
+		checkOnce(t, counts, n)
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	data := []byte(testLog(100))
+	for _, n := range []int{1, 2, 3, 8, 1000} {
+		segments := splitLines(data, n, 64)
+		if len(segments) > n {
+			t.Errorf("n=%d: got %d segments", n, len(segments))
+		}
+		var joined []byte
+		for i, s := range segments {
+			if i < len(segments)-1 && s[len(s)-1] != '\n' {
+				t.Errorf("n=%d: segment %d does not end at a line boundary", n, i)
+			}
+			joined = append(joined, s...)
+		}
+		if string(joined) != string(data) {
+			t.Errorf("n=%d: segments do not join to the data", n)
+		}
+	}
+	if got := splitLines(data[:100], 4, 64); len(got) != 1 {
+		t.Errorf("data under minSize was split into %d segments", len(got))
+	}
+}
+
+func TestWindowSecondsPrefixesSlot(t *testing.T) {
+	m, err := newMapper(&pb.MapRequest{Query: &pb.QuerySpec{GroupBy: []string{"status"}, WindowSeconds: 60}}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.write([]byte(testLog(3)))
+	m.response()
+	// 10/Oct/2000:13:55:36 -0700 is 971211336 in Unix seconds
+	want := map[string]int64{"971211300|200": 3}
+	if fmt.Sprint(m.tally.counts) != fmt.Sprint(want) {
+		t.Errorf("counts %v, want %v", m.tally.counts, want)
+	}
+}
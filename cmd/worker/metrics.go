@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"path"
+	"time"
+
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// registry holds the metrics of the worker, served on -metrics-listen
+var registry = metrics.NewRegistry()
+
+var (
+	requests = registry.NewCounter("loganalyzer_worker_requests_total",
+		"Calls handled by gRPC method and status code.", "method", "code")
+	requestDuration = registry.NewHistogram("loganalyzer_worker_request_duration_seconds",
+		"Duration of the calls handled by gRPC method.", metrics.DefaultBuckets, "method")
+	inFlightRequests = registry.NewGauge("loganalyzer_worker_inflight_requests",
+		"Calls being handled by gRPC method.", "method")
+	bytesProcessed = registry.NewCounter("loganalyzer_worker_bytes_processed_total",
+		"Bytes of log data parsed by map calls.")
+	linesParsed = registry.NewCounter("loganalyzer_worker_lines_total",
+		"Lines of the map calls by outcome: read, matched, rejected, skipped or filtered.", "outcome")
+)
+
+// observeMap records the data a map call parsed
+func observeMap(m *mapper, st *pb.MapStats) {
+	bytesProcessed.Add(float64(m.processed))
+	for outcome, n := range map[string]int64{"read": st.Lines, "matched": st.Matched, "rejected": st.Rejected, "skipped": st.Skipped, "filtered": st.Filtered} {
+		linesParsed.Add(float64(n), outcome)
+	}
+}
+
+// observe records a call to method that started at started
+func observe(method string, started time.Time, err error) {
+	requests.Inc(method, status.Code(err).String())
+	requestDuration.Observe(time.Since(started).Seconds(), method)
+}
+
+func metricsUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	method := path.Base(info.FullMethod)
+	inFlightRequests.Add(1, method)
+	defer inFlightRequests.Add(-1, method)
+	started := time.Now()
+	resp, err := handler(ctx, req)
+	observe(method, started, err)
+	return resp, err
+}
+
+func metricsStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	method := path.Base(info.FullMethod)
+	inFlightRequests.Add(1, method)
+	defer inFlightRequests.Add(-1, method)
+	started := time.Now()
+	err := handler(srv, ss)
+	observe(method, started, err)
+	return err
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"hash/fnv"
+	"io"
+	"log"
+
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/auth"
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ProcessReduce handles the Reduce phase of the job for a single partition
+func (s *workerServer) ProcessReduce(ctx context.Context, req *pb.ReduceRequest) (*pb.ReduceResponse, error) {
+	log.Printf("[WORKER] Recieved Reduce request for partition %d (%d partial results)", req.Partition, len(req.PartialResults))
+	if err := auth.CheckJob(ctx, req.JobId); err != nil {
+		return nil, err
+	}
+	counts := reduce(req.PartialResults)
+	return &pb.ReduceResponse{Results: aggregate(counts)}, nil
+}
+
+// ProcessReduceStream handles the Reduce phase for a partition sent as a
+// stream of batches, summing the counts of every batch before answering
+func (s *workerServer) ProcessReduceStream(stream grpc.ClientStreamingServer[pb.ReduceRequest, pb.ReduceResponse]) error {
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return status.Error(codes.InvalidArgument, "empty reduce stream")
+	}
+	if err != nil {
+		return err
+	}
+	if err := auth.CheckJob(stream.Context(), first.JobId); err != nil {
+		return err
+	}
+	counts := reduce(first.PartialResults)
+	batches, partials := 1, len(first.PartialResults)
+	for {
+		batch, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		for _, pr := range batch.PartialResults {
+			counts[pr.Key] += pr.Count
+		}
+		batches++
+		partials += len(batch.PartialResults)
+	}
+	log.Printf("[WORKER] Reduced partition %d from %d batches (%d partial results) into %d keys", first.Partition, batches, partials, len(counts))
+	return stream.SendAndClose(&pb.ReduceResponse{Results: aggregate(counts)})
+}
+
+// aggregate turns summed counts into the results of a partition
+func aggregate(counts map[string]int64) []*pb.AggregatedResult {
+	results := make([]*pb.AggregatedResult, 0, len(counts))
+	for k, v := range counts {
+		results = append(results, &pb.AggregatedResult{
+			Key:        k,
+			TotalCount: v,
+		})
+	}
+	return results
+}
+
+// reduce aggregates partial results from multiple chunks into a single map.
+// It takes a slice of PartialResult pointers and returns a map where the
+// keys are the same as the PartialResult keys and the values are the sum of
+// the counts for each key.
+//
+// Parameters:
+//
+//	partials - a slice of pointers to PartialResult, each containing a key and
+//	           a count.
+//
+// Returns:
+//
+//	A map where each key is a string from the PartialResult and the value is
+//	the total count for that key.
+func reduce(partials []*pb.PartialResult) map[string]int64 {
+	counts := make(map[string]int64)
+	for _, pr := range partials {
+		counts[pr.Key] += pr.Count
+	}
+	return counts
+}
+
+// partition returns the reduce partition a key belongs to. Every worker must
+// hash keys the same way so that a key always ends up in a single partition.
+func partition(key string, numPartitions int32) int32 {
+	if numPartitions <= 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int32(h.Sum32() % uint32(numPartitions))
+}
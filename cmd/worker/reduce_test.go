@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestProcessReduceStreamSumsBatches(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	pb.RegisterMapReduceServiceServer(server, &workerServer{})
+	go server.Serve(lis)
+	defer server.Stop()
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	stream, err := pb.NewMapReduceServiceClient(conn).ProcessReduceStream(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the same keys arrive in several batches, as many chunks send them
+	for batch := 0; batch < 3; batch++ {
+		err := stream.Send(&pb.ReduceRequest{Partition: 1, PartialResults: []*pb.PartialResult{
+			{Key: "200", Count: 2, Partition: 1},
+			{Key: "404", Count: 1, Partition: 1},
+			{Key: "200", Count: 1, Partition: 1},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]int64)
+	for _, r := range resp.Results {
+		got[r.Key] += r.TotalCount
+	}
+	if len(resp.Results) != 2 || got["200"] != 9 || got["404"] != 3 {
+		t.Errorf("results = %v, want 200:9 404:3", resp.Results)
+	}
+
+	// an empty stream is an error
+	empty, err := pb.NewMapReduceServiceClient(conn).ProcessReduceStream(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := empty.CloseAndRecv(); err == nil {
+		t.Error("empty reduce stream succeeded")
+	}
+}
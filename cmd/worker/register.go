@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"google.golang.org/grpc"
+)
+
+// version is reported to the master when the worker registers
+const version = "0.1.0"
+
+// registerRetryInterval is how long to wait before retrying a failed registration
+const registerRetryInterval = 2 * time.Second
+
+// registration announces the worker to the master and keeps it alive with heartbeats
+type registration struct {
+	masterAddr  string
+	address     string
+	capacity    int32
+	parallelism int32
+	// dialOpts hold the transport and call credentials used to dial the
+	// master
+	dialOpts []grpc.DialOption
+}
+
+// run registers with the master and sends heartbeats until the context is
+// cancelled. If the master forgets the worker (for example after a restart or
+// an eviction) the worker registers again.
+func (r *registration) run(ctx context.Context) {
+	conn, err := grpc.NewClient(r.masterAddr, r.dialOpts...)
+	if err != nil {
+		log.Fatalf("Failed to create master client for %s: %v", r.masterAddr, err)
+	}
+	defer conn.Close()
+	client := pb.NewMapReduceServiceClient(conn)
+
+	for ctx.Err() == nil {
+		id, interval := r.register(ctx, client)
+		if id == "" {
+			return
+		}
+		r.heartbeat(ctx, client, id, interval)
+	}
+}
+
+// register calls RegisterWorker until it succeeds. It returns the ID and
+// heartbeat interval assigned by the master, or an empty ID if the context
+// was cancelled.
+func (r *registration) register(ctx context.Context, client pb.MapReduceServiceClient) (string, time.Duration) {
+	req := &pb.RegisterRequest{
+		Address:     r.address,
+		Capacity:    r.capacity,
+		Version:     version,
+		Parallelism: r.parallelism,
+	}
+	for {
+		resp, err := client.RegisterWorker(ctx, req)
+		if err == nil {
+			log.Printf("[WORKER] Registered with master %s as %s", r.masterAddr, resp.WorkerId)
+			return resp.WorkerId, time.Duration(resp.HeartbeatIntervalMs) * time.Millisecond
+		}
+		log.Printf("[WORKER] Failed to register with master %s: %v", r.masterAddr, err)
+		select {
+		case <-ctx.Done():
+			return "", 0
+		case <-time.After(registerRetryInterval):
+		}
+	}
+}
+
+// heartbeat sends heartbeats every interval and returns when the master no
+// longer knows the worker or the context is cancelled. Failed heartbeats are
+// logged and retried; the master evicts the worker if they keep failing.
+func (r *registration) heartbeat(ctx context.Context, client pb.MapReduceServiceClient, id string, interval time.Duration) {
+	if interval <= 0 {
+		interval = registerRetryInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		resp, err := client.Heartbeat(ctx, &pb.HeartbeatRequest{WorkerId: id})
+		if err != nil {
+			log.Printf("[WORKER] Heartbeat to master %s failed: %v", r.masterAddr, err)
+			continue
+		}
+		if !resp.Known {
+			log.Printf("[WORKER] Master no longer knows %s, registering again", id)
+			return
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io"
+	"log"
+
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/auth"
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ProcessMapStream handles the Map phase of the job for a chunk sent as a
+// stream of frames. Each frame is processed as soon as it arrives so only one
+// frame of the chunk is held in memory at a time.
+func (s *workerServer) ProcessMapStream(stream grpc.ClientStreamingServer[pb.MapRequest, pb.MapResponse]) error {
+	// The first frame carries the job settings
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return status.Error(codes.InvalidArgument, "empty map stream")
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("[WORKER] Recieved Map stream for chunk %s", first.ChunkId)
+	if err := auth.CheckJob(stream.Context(), first.JobId); err != nil {
+		return err
+	}
+	m, err := newMapper(first, s.parallelism)
+	if err != nil {
+		return err
+	}
+	m.write(first.LogData)
+	// Process the remaining frames until the master closes the stream
+	frames := 1
+	for {
+		frame, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		m.write(frame.LogData)
+		frames++
+	}
+	log.Printf("[WORKER] Processed %d frames for chunk %s", frames, first.ChunkId)
+	resp := m.response()
+	observeMap(m, resp.Stats)
+	return stream.SendAndClose(resp)
+}
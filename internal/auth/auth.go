@@ -0,0 +1,312 @@
+// Package auth authenticates the calls between the master and the workers.
+// A server accepts either a bearer token from a list of named tokens, or a
+// job credential: an audience, an ID, an expiry time and a nonce signed with
+// HMAC-SHA256 under a key shared by the master and the workers. The master
+// signs credentials for the workers with its job ID, and the workers check
+// that the job ID in each request matches it (see CheckJob). The workers
+// sign credentials for the master with their own name when they register.
+// Callers sign a fresh credential for every call and servers accept each
+// only once, so a credential that leaks cannot be replayed on the server it
+// was sent to and expires soon on the others. Anyone with the key can sign
+// any credential: the key must stay with the master and the workers.
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MetadataKey is the metadata key that carries the credential
+const MetadataKey = "authorization"
+
+// Schemes of the authorization metadata
+const (
+	schemeBearer = "Bearer"
+	schemeJob    = "Job"
+)
+
+// Token is a bearer token and the name of the caller it identifies
+type Token struct {
+	Name   string
+	Secret string
+}
+
+// LoadTokens reads a token file: one token per line, preceded by the name
+// of its caller and a space (ex. "master-prod 3f9c..."), or alone, in which
+// case the caller is named after the line. Empty lines and lines starting
+// with # are ignored.
+func LoadTokens(path string) ([]Token, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var tokens []Token
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		t := Token{Name: "line " + strconv.Itoa(n), Secret: line}
+		if name, secret, ok := strings.Cut(line, " "); ok {
+			t = Token{Name: name, Secret: strings.TrimSpace(secret)}
+		}
+		if strings.ContainsAny(t.Secret, " \t") {
+			return nil, fmt.Errorf("%s:%d: want a name and a token separated by a space", path, n)
+		}
+		tokens = append(tokens, t)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%s: no tokens", path)
+	}
+	return tokens, nil
+}
+
+// LoadSecret reads a token or key from a file, ignoring surrounding
+// whitespace
+func LoadSecret(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	return data, nil
+}
+
+// Audiences of job credentials. The master signs credentials for the
+// workers it calls, and the workers sign credentials for the master they
+// register with, so a credential a worker sent cannot be used to call
+// another worker.
+const (
+	AudienceWorker = "worker"
+	AudienceMaster = "master"
+)
+
+// JobCredential is the signed content of a job credential
+type JobCredential struct {
+	// Audience is the kind of server the credential is for
+	Audience string
+	// Job is the job ID of the master, or the name of a worker
+	Job     string
+	Expires time.Time
+	// Nonce is random, so servers can reject a credential they already saw
+	Nonce string
+}
+
+// SignJob returns a credential for the ID job, the job ID of the master or
+// the name of a worker, that servers of the audience accept until expires
+// and only once. IDs must not contain dots.
+func SignJob(key []byte, audience, job string, expires time.Time) string {
+	nonce := make([]byte, 12)
+	rand.Read(nonce)
+	payload := strings.Join([]string{audience, job, strconv.FormatInt(expires.Unix(), 10), base64.RawURLEncoding.EncodeToString(nonce)}, ".")
+	return payload + "." + signature(key, payload)
+}
+
+// VerifyJob checks the signature and expiry of a job credential and returns
+// its content
+func VerifyJob(key []byte, credential string, now time.Time) (JobCredential, error) {
+	i := strings.LastIndexByte(credential, '.')
+	if i < 0 {
+		return JobCredential{}, errors.New("malformed job credential")
+	}
+	payload, sig := credential[:i], credential[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(signature(key, payload))) {
+		return JobCredential{}, errors.New("invalid job credential signature")
+	}
+	fields := strings.Split(payload, ".")
+	if len(fields) != 4 {
+		return JobCredential{}, errors.New("malformed job credential")
+	}
+	expires, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return JobCredential{}, errors.New("malformed job credential expiry")
+	}
+	c := JobCredential{Audience: fields[0], Job: fields[1], Expires: time.Unix(expires, 0), Nonce: fields[3]}
+	if now.After(c.Expires) {
+		return JobCredential{}, fmt.Errorf("job credential of %s expired at %s", c.Job, c.Expires.UTC().Format(time.RFC3339))
+	}
+	return c, nil
+}
+
+func signature(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verifier authenticates incoming calls
+type Verifier struct {
+	tokens   []Token
+	key      []byte
+	audience string
+
+	mu sync.Mutex
+	// seen holds the nonces of the job credentials accepted so far, until
+	// they expire, so that none is accepted twice
+	seen   map[string]time.Time
+	pruned time.Time
+}
+
+// NewVerifier accepts the given tokens and job credentials for audience
+// signed with key. Either may be empty, but not both.
+func NewVerifier(tokens []Token, key []byte, audience string) (*Verifier, error) {
+	if len(tokens) == 0 && len(key) == 0 {
+		return nil, errors.New("no tokens or job key to authenticate with")
+	}
+	return &Verifier{tokens: tokens, key: key, audience: audience, seen: make(map[string]time.Time)}, nil
+}
+
+// Caller identifies the sender of an authenticated call
+type Caller struct {
+	// Name describes the credential, ex. "token master-prod" or
+	// "job job-1x2y"
+	Name string
+	// Job is the ID of a job credential, empty for a bearer token
+	Job string
+}
+
+// Authenticate checks the credential in the metadata of an incoming call and
+// returns its caller. Failures are codes.Unauthenticated errors.
+func (v *Verifier) Authenticate(ctx context.Context) (Caller, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(MetadataKey)
+	if len(values) == 0 {
+		return Caller{}, status.Error(codes.Unauthenticated, "missing credentials")
+	}
+	scheme, credential, _ := strings.Cut(values[0], " ")
+	switch {
+	case strings.EqualFold(scheme, schemeBearer) && len(v.tokens) > 0:
+		if name, ok := v.token(credential); ok {
+			return Caller{Name: "token " + name}, nil
+		}
+		return Caller{}, status.Error(codes.Unauthenticated, "unknown token")
+	case strings.EqualFold(scheme, schemeJob) && len(v.key) > 0:
+		now := time.Now()
+		c, err := VerifyJob(v.key, credential, now)
+		if err != nil {
+			return Caller{}, status.Error(codes.Unauthenticated, err.Error())
+		}
+		if c.Audience != v.audience {
+			return Caller{}, status.Errorf(codes.Unauthenticated, "job credential for a %s, not a %s", c.Audience, v.audience)
+		}
+		if !v.firstUse(c, now) {
+			return Caller{}, status.Error(codes.Unauthenticated, "job credential already used")
+		}
+		return Caller{Name: "job " + c.Job, Job: c.Job}, nil
+	}
+	return Caller{}, status.Errorf(codes.Unauthenticated, "unsupported authorization scheme %q", scheme)
+}
+
+// firstUse records the nonce of a credential and reports whether it was
+// new. Nonces are forgotten once their credential expires.
+func (v *Verifier) firstUse(c JobCredential, now time.Time) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if now.Sub(v.pruned) > time.Minute {
+		for nonce, expires := range v.seen {
+			if now.After(expires) {
+				delete(v.seen, nonce)
+			}
+		}
+		v.pruned = now
+	}
+	if _, ok := v.seen[c.Nonce]; ok {
+		return false
+	}
+	v.seen[c.Nonce] = c.Expires
+	return true
+}
+
+type callerKey struct{}
+
+// withCaller returns a context that carries the caller of a call
+func withCaller(ctx context.Context, c Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, c)
+}
+
+// CheckJob fails with codes.PermissionDenied if the call of ctx was
+// authenticated with a job credential for another job than job. Calls with
+// bearer tokens, or on servers without authentication, may name any job.
+func CheckJob(ctx context.Context, job string) error {
+	c, _ := ctx.Value(callerKey{}).(Caller)
+	if c.Job != "" && c.Job != job {
+		return status.Errorf(codes.PermissionDenied, "credential for job %s does not allow calls for job %q", c.Job, job)
+	}
+	return nil
+}
+
+// token looks a bearer token up, comparing every token in constant time
+func (v *Verifier) token(secret string) (string, bool) {
+	name, found := "", false
+	for _, t := range v.tokens {
+		if subtle.ConstantTimeCompare([]byte(t.Secret), []byte(secret)) == 1 && !found {
+			name, found = t.Name, true
+		}
+	}
+	return name, found
+}
+
+// Credentials attach a bearer token or a job credential to every call. They
+// implement credentials.PerRPCCredentials.
+type Credentials struct {
+	token    string
+	key      []byte
+	audience string
+	job      string
+	ttl      time.Duration
+	// secure requires the connection to be encrypted
+	secure bool
+}
+
+// NewTokenCredentials sends token as a bearer token
+func NewTokenCredentials(token string, secure bool) *Credentials {
+	return &Credentials{token: token, secure: secure}
+}
+
+// NewJobCredentials sends credentials for the ID job to servers of audience,
+// signed with key, that expire ttl after each call starts
+func NewJobCredentials(key []byte, audience, job string, ttl time.Duration, secure bool) (*Credentials, error) {
+	if job == "" || strings.Contains(job, ".") {
+		return nil, fmt.Errorf("invalid job ID %q", job)
+	}
+	return &Credentials{key: key, audience: audience, job: job, ttl: ttl, secure: secure}, nil
+}
+
+// GetRequestMetadata returns the authorization metadata of a call
+func (c *Credentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	if c.key != nil {
+		return map[string]string{MetadataKey: schemeJob + " " + SignJob(c.key, c.audience, c.job, time.Now().Add(c.ttl))}, nil
+	}
+	return map[string]string{MetadataKey: schemeBearer + " " + c.token}, nil
+}
+
+// RequireTransportSecurity reports whether the credentials may only be sent
+// over TLS
+func (c *Credentials) RequireTransportSecurity() bool {
+	return c.secure
+}
@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestLoadTokens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	os.WriteFile(path, []byte("# tokens\nmaster-prod s3cret-one\n\n  s3cret-two  \n"), 0o600)
+	tokens, err := LoadTokens(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Token{{Name: "master-prod", Secret: "s3cret-one"}, {Name: "line 4", Secret: "s3cret-two"}}
+	if len(tokens) != len(want) || tokens[0] != want[0] || tokens[1] != want[1] {
+		t.Errorf("LoadTokens = %v, want %v", tokens, want)
+	}
+	for _, bad := range []string{"# only a comment\n", "name two tokens\n"} {
+		os.WriteFile(path, []byte(bad), 0o600)
+		if _, err := LoadTokens(path); err == nil {
+			t.Errorf("loaded %q", bad)
+		}
+	}
+}
+
+func TestVerifyJob(t *testing.T) {
+	key := []byte("key")
+	now := time.Unix(1700000000, 0)
+	credential := SignJob(key, AudienceWorker, "job-1x2y", now.Add(time.Minute))
+	c, err := VerifyJob(key, credential, now)
+	if err != nil || c.Job != "job-1x2y" || c.Audience != AudienceWorker || c.Nonce == "" {
+		t.Fatalf("VerifyJob = %+v, %v", c, err)
+	}
+	if again := SignJob(key, AudienceWorker, "job-1x2y", now.Add(time.Minute)); again == credential {
+		t.Error("two credentials share a nonce")
+	}
+	if _, err := VerifyJob(key, credential, now.Add(2*time.Minute)); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("expired credential: %v", err)
+	}
+	if _, err := VerifyJob([]byte("other key"), credential, now); err == nil {
+		t.Error("verified a credential signed with another key")
+	}
+	// the audience, job and expiry are covered by the signature
+	for _, forged := range []string{
+		strings.Replace(credential, ".1700000060.", ".1800000000.", 1),
+		strings.Replace(credential, "job-1x2y", "job-2", 1),
+		strings.Replace(credential, AudienceWorker, AudienceMaster, 1),
+	} {
+		if _, err := VerifyJob(key, forged, now); err == nil {
+			t.Errorf("verified the forged credential %s", forged)
+		}
+	}
+	if _, err := VerifyJob(key, "garbage", now); err == nil {
+		t.Error("verified a malformed credential")
+	}
+	if _, err := NewJobCredentials(key, AudienceWorker, "job.1", time.Minute, false); err == nil {
+		t.Error("accepted a job ID with a dot")
+	}
+}
+
+// incoming returns the context of a call that carries the metadata of creds
+func incoming(t *testing.T, creds *Credentials) context.Context {
+	t.Helper()
+	md, err := creds.GetRequestMetadata(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return metadata.NewIncomingContext(context.Background(), metadata.New(md))
+}
+
+func TestAuthenticate(t *testing.T) {
+	key := []byte("key")
+	v, err := NewVerifier([]Token{{Name: "ops", Secret: "s3cret"}}, key, AudienceWorker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobCreds, err := NewJobCredentials(key, AudienceWorker, "job-1", time.Minute, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, _ := NewJobCredentials([]byte("other key"), AudienceWorker, "job-1", time.Minute, false)
+	// credentials a worker signs to register are not accepted by workers
+	registration, _ := NewJobCredentials(key, AudienceMaster, "worker-1", time.Minute, false)
+	for _, c := range []struct {
+		name   string
+		ctx    context.Context
+		caller Caller
+	}{
+		{"token", incoming(t, NewTokenCredentials("s3cret", false)), Caller{Name: "token ops"}},
+		{"job credential", incoming(t, jobCreds), Caller{Name: "job job-1", Job: "job-1"}},
+		{"unknown token", incoming(t, NewTokenCredentials("guess", false)), Caller{}},
+		{"other key", incoming(t, otherKey), Caller{}},
+		{"other audience", incoming(t, registration), Caller{}},
+		{"no credentials", context.Background(), Caller{}},
+		{"unknown scheme", metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, "Basic b3BzOnMzY3JldA==")), Caller{}},
+	} {
+		caller, err := v.Authenticate(c.ctx)
+		if caller != c.caller {
+			t.Errorf("%s: Authenticate = %+v, %v, want %+v", c.name, caller, err, c.caller)
+		}
+		if c.caller.Name == "" && status.Code(err) != codes.Unauthenticated {
+			t.Errorf("%s: error %v, want Unauthenticated", c.name, err)
+		}
+	}
+
+	// a job credential is accepted once
+	replayed := incoming(t, jobCreds)
+	if _, err := v.Authenticate(replayed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Authenticate(replayed); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("replayed credential: %v, want Unauthenticated", err)
+	}
+
+	// a verifier without a key does not accept job credentials
+	tokensOnly, _ := NewVerifier([]Token{{Name: "ops", Secret: "s3cret"}}, nil, AudienceWorker)
+	if _, err := tokensOnly.Authenticate(incoming(t, jobCreds)); err == nil {
+		t.Error("accepted a job credential without a key")
+	}
+	if _, err := NewVerifier(nil, nil, AudienceWorker); err == nil {
+		t.Error("created a verifier that accepts nothing")
+	}
+}
+
+func TestCheckJob(t *testing.T) {
+	ctx := withCaller(context.Background(), Caller{Name: "job job-1", Job: "job-1"})
+	if err := CheckJob(ctx, "job-1"); err != nil {
+		t.Errorf("CheckJob of the credential's job: %v", err)
+	}
+	for _, job := range []string{"job-2", ""} {
+		if err := CheckJob(ctx, job); status.Code(err) != codes.PermissionDenied {
+			t.Errorf("CheckJob(%q) = %v, want PermissionDenied", job, err)
+		}
+	}
+	// bearer tokens and servers without authentication do not bind jobs
+	if err := CheckJob(withCaller(context.Background(), Caller{Name: "token ops"}), "job-2"); err != nil {
+		t.Errorf("CheckJob with a token: %v", err)
+	}
+	if err := CheckJob(context.Background(), "job-2"); err != nil {
+		t.Errorf("CheckJob without authentication: %v", err)
+	}
+}
+
+func TestInterceptor(t *testing.T) {
+	key := []byte("key")
+	v, _ := NewVerifier([]Token{{Name: "ops", Secret: "s3cret"}}, key, AudienceWorker)
+	var logs []string
+	i := &Interceptor{Verifier: v, Logf: func(format string, args ...any) {
+		logs = append(logs, format)
+	}, Quiet: map[string]bool{"Heartbeat": true}}
+	handled := 0
+	var job string
+	handler := func(ctx context.Context, req any) (any, error) {
+		handled++
+		return nil, CheckJob(ctx, job)
+	}
+	call := func(ctx context.Context, method string) error {
+		_, err := i.Unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return err
+	}
+
+	if err := call(context.Background(), "/mapreduce.MapReduceService/RegisterWorker"); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("call without credentials: %v", err)
+	}
+	if err := call(incoming(t, NewTokenCredentials("s3cret", false)), "/mapreduce.MapReduceService/RegisterWorker"); err != nil {
+		t.Errorf("call with a token: %v", err)
+	}
+	if err := call(incoming(t, NewTokenCredentials("s3cret", false)), "/mapreduce.MapReduceService/Heartbeat"); err != nil {
+		t.Errorf("quiet call with a token: %v", err)
+	}
+	// health checks do not need credentials
+	if err := call(context.Background(), "/grpc.health.v1.Health/Check"); err != nil {
+		t.Errorf("health check: %v", err)
+	}
+	// the handler sees the job of the credential
+	creds, _ := NewJobCredentials(key, AudienceWorker, "job-1", time.Minute, false)
+	job = "job-2"
+	if err := call(incoming(t, creds), "/mapreduce.MapReduceService/ProcessMap"); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("call for another job: %v, want PermissionDenied", err)
+	}
+	if handled != 4 {
+		t.Errorf("handled %d calls, want 4", handled)
+	}
+	if len(logs) != 3 {
+		t.Errorf("logged %d calls, want the rejection and two authenticated calls: %v", len(logs), logs)
+	}
+}
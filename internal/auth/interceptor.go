@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Interceptor rejects calls to a gRPC server without valid credentials and
+// logs who made every call
+type Interceptor struct {
+	Verifier *Verifier
+	// Logf reports every call and rejection
+	Logf func(format string, args ...any)
+	// Quiet names methods, ex. "Heartbeat", whose authenticated calls are
+	// not logged because they are made all the time
+	Quiet map[string]bool
+}
+
+// check authenticates a call to method and returns its context with the
+// caller, for CheckJob
+func (i *Interceptor) check(ctx context.Context, method string) (context.Context, error) {
+	c, err := i.Verifier.Authenticate(ctx)
+	if err != nil {
+		i.Logf("Rejected %s from %s: %s", method, caller(ctx), status.Convert(err).Message())
+		return nil, err
+	}
+	if !i.Quiet[method] {
+		i.Logf("%s from %s authenticated as %s", method, caller(ctx), c.Name)
+	}
+	return withCaller(ctx, c), nil
+}
+
+// Health checks are answered without credentials so that orchestrators can
+// probe the server
+func isHealthCheck(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/"+healthpb.Health_ServiceDesc.ServiceName+"/")
+}
+
+// Unary is a grpc.UnaryServerInterceptor
+func (i *Interceptor) Unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if isHealthCheck(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	ctx, err := i.check(ctx, path.Base(info.FullMethod))
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// Stream is a grpc.StreamServerInterceptor
+func (i *Interceptor) Stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if isHealthCheck(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	ctx, err := i.check(ss.Context(), path.Base(info.FullMethod))
+	if err != nil {
+		return err
+	}
+	return handler(srv, &callerStream{ServerStream: ss, ctx: ctx})
+}
+
+// callerStream is a server stream whose context carries its caller
+type callerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *callerStream) Context() context.Context {
+	return s.ctx
+}
+
+// caller describes the peer of a call: its address and, with mutual TLS,
+// the subject of its certificate
+func caller(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "unknown peer"
+	}
+	if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 {
+		return p.Addr.String() + " (" + info.State.VerifiedChains[0][0].Subject.String() + ")"
+	}
+	return p.Addr.String()
+}
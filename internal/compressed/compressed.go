@@ -0,0 +1,85 @@
+// Package compressed detects compressed log files by their magic bytes and
+// decompresses them as a stream. For formats that record where their
+// independent members start, BGZF and multi-frame or seekable zstd, it also
+// finds the member boundaries so a file can be split into ranges that are
+// decompressed in parallel.
+package compressed
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression formats
+const (
+	None  = ""
+	Gzip  = "gzip"
+	Zstd  = "zstd"
+	Bzip2 = "bzip2"
+)
+
+// magics are the leading bytes of each format
+var magics = []struct {
+	kind  string
+	magic []byte
+}{
+	{Gzip, []byte{0x1f, 0x8b}},
+	{Zstd, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{Bzip2, []byte("BZh")},
+}
+
+// magicSize is how many bytes Detect needs
+const magicSize = 4
+
+// Detect returns the compression of data that starts with header, or None
+func Detect(header []byte) string {
+	for _, m := range magics {
+		if bytes.HasPrefix(header, m.magic) {
+			return m.kind
+		}
+	}
+	return None
+}
+
+// NewReader returns a reader that decompresses r, which holds data in the
+// given compression. Concatenated gzip members and zstd frames are read as
+// one stream.
+func NewReader(r io.Reader, kind string) (io.ReadCloser, error) {
+	switch kind {
+	case None:
+		return io.NopCloser(r), nil
+	case Gzip:
+		return gzip.NewReader(r)
+	case Zstd:
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	case Bzip2:
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	}
+	return nil, fmt.Errorf("unknown compression %q", kind)
+}
+
+// Open detects the compression of r from its first bytes and returns a
+// reader of the decompressed data with the compression found
+func Open(r io.Reader) (io.ReadCloser, string, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(magicSize)
+	if err != nil && err != io.EOF {
+		return nil, None, err
+	}
+	kind := Detect(header)
+	rc, err := NewReader(br, kind)
+	if err != nil {
+		return nil, kind, fmt.Errorf("open %s stream: %w", kind, err)
+	}
+	return rc, kind, nil
+}
@@ -0,0 +1,165 @@
+package compressed
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Split is a range of a compressed file that starts at a member boundary
+// and can be decompressed on its own. Offset and Length are in the
+// compressed file, RawOffset and RawLength in the decompressed data.
+// RawLength is -1 when the decompressed size is unknown, in which case the
+// split is the whole file.
+type Split struct {
+	Offset    int64
+	Length    int64
+	RawOffset int64
+	RawLength int64
+	// LineStart is set when the decompressed data of the split starts at the
+	// beginning of a line
+	LineStart bool
+}
+
+// member is one independently compressed part of a file
+type member struct {
+	offset, size int64
+	// rawSize is the decompressed size, or -1 if it is unknown
+	rawSize int64
+	// last is the last decompressed byte, valid when known is set
+	last  byte
+	known bool
+}
+
+// Splits cuts a compressed file of the given size into splits of about
+// target compressed bytes, never cutting a member. Members are only found
+// when that does not take decompressing the file: BGZF files and zstd files
+// whose frames record their size, such as seekable zstd. Other files give a
+// single split of unknown decompressed size. Bzip2 blocks are not byte
+// aligned, so bzip2 files cannot be split.
+func Splits(f io.ReaderAt, size int64, kind string, target int64) ([]Split, error) {
+	var (
+		members []member
+		err     error
+	)
+	switch kind {
+	case Gzip:
+		members, err = gzipMembers(f, size)
+	case Zstd:
+		members, err = zstdMembers(f, size)
+	case Bzip2:
+		return nil, errors.New("bzip2 files cannot be split, decompress them first or read them without shared storage")
+	default:
+		return nil, fmt.Errorf("unknown compression %q", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if members == nil {
+		return []Split{{Length: size, RawLength: -1, LineStart: true}}, nil
+	}
+	var (
+		splits []Split
+		raw    int64
+		prev   *member
+	)
+	for i := 0; i < len(members); {
+		s := Split{Offset: members[i].offset, RawOffset: raw, LineStart: true}
+		if prev != nil {
+			last, err := lastByte(f, kind, prev)
+			if err != nil {
+				return nil, err
+			}
+			s.LineStart = last == '\n'
+		}
+		// members without content, such as skippable zstd frames, join the
+		// split before them
+		for ; i < len(members) && (s.Length == 0 || s.Length < target || members[i].rawSize == 0); i++ {
+			m := &members[i]
+			s.Length = m.offset + m.size - s.Offset
+			s.RawLength += m.rawSize
+			if m.rawSize > 0 {
+				prev = m
+			}
+		}
+		raw += s.RawLength
+		splits = append(splits, s)
+	}
+	return splits, nil
+}
+
+// lastByte returns the last decompressed byte of a member, decompressing it
+// if it is not known yet
+func lastByte(f io.ReaderAt, kind string, m *member) (byte, error) {
+	if m.known {
+		return m.last, nil
+	}
+	r, err := NewReader(io.NewSectionReader(f, m.offset, m.size), kind)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	var t tail
+	if _, err := io.Copy(&t, r); err != nil {
+		return 0, fmt.Errorf("decompress member at %d: %w", m.offset, err)
+	}
+	m.last, m.known = t.last, true
+	return m.last, nil
+}
+
+// tail is a writer that keeps the last byte written
+type tail struct {
+	last byte
+}
+
+func (t *tail) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		t.last = p[len(p)-1]
+	}
+	return len(p), nil
+}
+
+// gzipMembers finds the members of a BGZF file, which records the size of
+// every member in its header. Finding the members of other gzip files would
+// take decompressing them, so it returns nil for them.
+func gzipMembers(f io.ReaderAt, size int64) ([]member, error) {
+	if members, ok := bgzfMembers(f, size); ok {
+		return members, nil
+	}
+	return nil, nil
+}
+
+// bgzfMembers walks the blocks of a BGZF file, whose headers hold the block
+// size in a "BC" extra subfield and whose trailers hold the decompressed
+// size. It reports false if any block lacks the subfield.
+func bgzfMembers(f io.ReaderAt, size int64) ([]member, bool) {
+	var members []member
+	header := make([]byte, 18)
+	trailer := make([]byte, 4)
+	for offset := int64(0); offset < size; {
+		if _, err := f.ReadAt(header, offset); err != nil {
+			return nil, false
+		}
+		// magic, deflate, FEXTRA, XLEN 6 and a BC subfield of 2 bytes
+		if header[0] != 0x1f || header[1] != 0x8b || header[2] != 8 || header[3]&4 == 0 ||
+			binary.LittleEndian.Uint16(header[10:]) != 6 || header[12] != 'B' || header[13] != 'C' ||
+			binary.LittleEndian.Uint16(header[14:]) != 2 {
+			return nil, false
+		}
+		blockSize := int64(binary.LittleEndian.Uint16(header[16:])) + 1
+		if offset+blockSize > size {
+			return nil, false
+		}
+		if _, err := f.ReadAt(trailer, offset+blockSize-4); err != nil {
+			return nil, false
+		}
+		members = append(members, member{
+			offset:  offset,
+			size:    blockSize,
+			rawSize: int64(binary.LittleEndian.Uint32(trailer)),
+		})
+		offset += blockSize
+	}
+	return members, len(members) > 0
+}
@@ -0,0 +1,231 @@
+package compressed
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// testData returns log-like lines of varying length
+func testData() []byte {
+	var b strings.Builder
+	for i := 0; i < 3000; i++ {
+		fmt.Fprintf(&b, "line %d %s\n", i, strings.Repeat("x", i%50))
+	}
+	return []byte(b.String())
+}
+
+// parts cuts data into pieces of size bytes, most of them ending mid-line
+func parts(data []byte, size int) [][]byte {
+	var all [][]byte
+	for len(data) > 0 {
+		n := min(size, len(data))
+		all = append(all, data[:n])
+		data = data[n:]
+	}
+	return all
+}
+
+// bgzfBlock compresses data into one BGZF block
+func bgzfBlock(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var deflated bytes.Buffer
+	w, err := flate.NewWriter(&deflated, flate.DefaultCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data)
+	w.Close()
+	block := []byte{0x1f, 0x8b, 8, 4, 0, 0, 0, 0, 0, 0xff, 6, 0, 'B', 'C', 2, 0, 0, 0}
+	binary.LittleEndian.PutUint16(block[16:], uint16(18+deflated.Len()+8-1))
+	block = append(block, deflated.Bytes()...)
+	block = binary.LittleEndian.AppendUint32(block, crc32.ChecksumIEEE(data))
+	return binary.LittleEndian.AppendUint32(block, uint32(len(data)))
+}
+
+func bgzf(t *testing.T, data []byte) []byte {
+	var file []byte
+	for _, p := range parts(data, 5000) {
+		file = append(file, bgzfBlock(t, p)...)
+	}
+	// BGZF files end with an empty block
+	return append(file, bgzfBlock(t, nil)...)
+}
+
+func gzipMembersOf(t *testing.T, data []byte) []byte {
+	var file bytes.Buffer
+	for _, p := range parts(data, 5000) {
+		w := gzip.NewWriter(&file)
+		w.Write(p)
+		w.Close()
+	}
+	return file.Bytes()
+}
+
+func zstdFrames(t *testing.T, data []byte) []byte {
+	t.Helper()
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+	var file []byte
+	for _, p := range parts(data, 5000) {
+		file = enc.EncodeAll(p, file)
+	}
+	return file
+}
+
+// seekable appends the seek table of the zstd seekable format to frames
+// made of the given parts
+func seekable(t *testing.T, data []byte) []byte {
+	t.Helper()
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+	var file, table []byte
+	ps := parts(data, 5000)
+	for _, p := range ps {
+		frame := enc.EncodeAll(p, nil)
+		file = append(file, frame...)
+		table = binary.LittleEndian.AppendUint32(table, uint32(len(frame)))
+		table = binary.LittleEndian.AppendUint32(table, uint32(len(p)))
+	}
+	table = binary.LittleEndian.AppendUint32(table, uint32(len(ps)))
+	table = append(table, 0)
+	table = binary.LittleEndian.AppendUint32(table, seekableFooterMagic)
+	file = binary.LittleEndian.AppendUint32(file, zstdSkippableMagic|0xe)
+	file = binary.LittleEndian.AppendUint32(file, uint32(len(table)))
+	return append(file, table...)
+}
+
+// zstdStream compresses data as a stream, whose frame does not record its
+// content size
+func zstdStream(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var file bytes.Buffer
+	enc, err := zstd.NewWriter(&file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the writer only records the content size when it has all the data
+	// before writing the frame header
+	for _, p := range parts(data, 1000) {
+		enc.Write(p)
+		enc.Flush()
+	}
+	enc.Close()
+	return file.Bytes()
+}
+
+// checkSplits fails unless the splits cover the file and each decompresses
+// on its own into its range of the data
+func checkSplits(t *testing.T, file []byte, kind string, splits []Split, data []byte) {
+	t.Helper()
+	var offset, raw int64
+	for i, s := range splits {
+		if s.Offset != offset || s.RawOffset != raw {
+			t.Fatalf("split %d starts at %d (raw %d), want %d (raw %d)", i, s.Offset, s.RawOffset, offset, raw)
+		}
+		r, err := NewReader(bytes.NewReader(file[s.Offset:s.Offset+s.Length]), kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("split %d: %v", i, err)
+		}
+		if !bytes.Equal(got, data[s.RawOffset:s.RawOffset+s.RawLength]) {
+			t.Fatalf("split %d does not decompress into its range", i)
+		}
+		if want := s.RawOffset == 0 || data[s.RawOffset-1] == '\n'; s.LineStart != want {
+			t.Errorf("split %d LineStart = %v, want %v", i, s.LineStart, want)
+		}
+		offset += s.Length
+		raw += s.RawLength
+	}
+	if offset != int64(len(file)) || raw != int64(len(data)) {
+		t.Errorf("splits cover %d bytes (raw %d), want %d (raw %d)", offset, raw, len(file), len(data))
+	}
+}
+
+func TestSplits(t *testing.T) {
+	data := testData()
+	for _, c := range []struct {
+		name string
+		kind string
+		file []byte
+	}{
+		{"bgzf", Gzip, bgzf(t, data)},
+		{"zstd frames", Zstd, zstdFrames(t, data)},
+		{"seekable zstd", Zstd, seekable(t, data)},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			for _, target := range []int64{1, 4000, 10000, int64(len(c.file))} {
+				splits, err := Splits(bytes.NewReader(c.file), int64(len(c.file)), c.kind, target)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if target == 1 && len(splits) < 10 {
+					t.Errorf("got %d splits with target 1", len(splits))
+				}
+				checkSplits(t, c.file, c.kind, splits, data)
+			}
+		})
+	}
+}
+
+func TestSplitsNeedDecompressing(t *testing.T) {
+	data := testData()
+	for _, c := range []struct {
+		name string
+		kind string
+		file []byte
+	}{
+		{"multi-member gzip", Gzip, gzipMembersOf(t, data)},
+		{"zstd stream", Zstd, zstdStream(t, data)},
+	} {
+		splits, err := Splits(bytes.NewReader(c.file), int64(len(c.file)), c.kind, 1)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		want := Split{Length: int64(len(c.file)), RawLength: -1, LineStart: true}
+		if len(splits) != 1 || splits[0] != want {
+			t.Errorf("%s: got %+v, want the whole file of unknown size", c.name, splits)
+		}
+	}
+	if _, err := Splits(bytes.NewReader(nil), 0, Bzip2, 1); err == nil {
+		t.Error("split a bzip2 file")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	data := testData()
+	for kind, file := range map[string][]byte{
+		None: data,
+		Gzip: gzipMembersOf(t, data),
+		Zstd: zstdFrames(t, data),
+	} {
+		r, got, err := Open(bytes.NewReader(file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != kind {
+			t.Errorf("detected %q, want %q", got, kind)
+		}
+		out, err := io.ReadAll(r)
+		if err != nil || !bytes.Equal(out, data) {
+			t.Errorf("%q: read %d bytes, %v", kind, len(out), err)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package compressed
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	zstdFrameMagic = 0xfd2fb528
+	// skippable frames have magics 0x184d2a50 to 0x184d2a5f
+	zstdSkippableMask  = 0xfffffff0
+	zstdSkippableMagic = 0x184d2a50
+	// seekableFooterMagic ends the seek table of the zstd seekable format
+	seekableFooterMagic = 0x8f92eab1
+	seekableFooterSize  = 9
+)
+
+// zstdMembers finds the frames of a zstd file, from the seek table of the
+// seekable format when the file has one, or else by walking the frame and
+// block headers. It returns nil if a frame does not record its content
+// size, which could only be found by decompressing it.
+func zstdMembers(f io.ReaderAt, size int64) ([]member, error) {
+	members, ok, err := seekTable(f, size)
+	if err != nil || ok {
+		return members, err
+	}
+	for offset := int64(0); offset < size; {
+		m, err := zstdFrame(f, offset, size)
+		if err != nil {
+			return nil, fmt.Errorf("zstd frame at %d: %w", offset, err)
+		}
+		if m.rawSize < 0 {
+			return nil, nil
+		}
+		members = append(members, m)
+		offset += m.size
+	}
+	return members, nil
+}
+
+// seekTable reads the seek table of a zstd seekable file, a skippable frame
+// at the end of the file that lists the compressed and decompressed size of
+// every frame. It reports false if the file has none.
+func seekTable(f io.ReaderAt, size int64) ([]member, bool, error) {
+	if size < seekableFooterSize+8 {
+		return nil, false, nil
+	}
+	footer := make([]byte, seekableFooterSize)
+	if _, err := f.ReadAt(footer, size-seekableFooterSize); err != nil {
+		return nil, false, err
+	}
+	if binary.LittleEndian.Uint32(footer[5:]) != seekableFooterMagic {
+		return nil, false, nil
+	}
+	frames := int64(binary.LittleEndian.Uint32(footer))
+	entrySize := int64(8)
+	if footer[4]&0x80 != 0 {
+		// each entry also has a checksum
+		entrySize = 12
+	}
+	tableSize := 8 + frames*entrySize + seekableFooterSize
+	if tableSize > size {
+		return nil, false, errors.New("zstd seek table larger than the file")
+	}
+	table := make([]byte, tableSize)
+	if _, err := f.ReadAt(table, size-tableSize); err != nil {
+		return nil, false, err
+	}
+	if binary.LittleEndian.Uint32(table)&zstdSkippableMask != zstdSkippableMagic {
+		return nil, false, errors.New("zstd seek table is not in a skippable frame")
+	}
+	members := make([]member, 0, frames)
+	var offset int64
+	for i := int64(0); i < frames; i++ {
+		entry := table[8+i*entrySize:]
+		m := member{
+			offset:  offset,
+			size:    int64(binary.LittleEndian.Uint32(entry)),
+			rawSize: int64(binary.LittleEndian.Uint32(entry[4:])),
+		}
+		offset += m.size
+		members = append(members, m)
+	}
+	if offset != size-tableSize {
+		return nil, false, fmt.Errorf("zstd seek table covers %d bytes, the frames take %d", offset, size-tableSize)
+	}
+	// the seek table is a skippable frame, a member without content
+	members = append(members, member{offset: offset, size: tableSize})
+	return members, true, nil
+}
+
+// zstdFrame reads the headers of the frame at offset and returns its size.
+// rawSize is -1 when the frame does not record its content size. Skippable
+// frames are members without content.
+func zstdFrame(f io.ReaderAt, offset, size int64) (member, error) {
+	m := member{offset: offset}
+	buf := make([]byte, 8)
+	read := func(n int, at int64) ([]byte, error) {
+		if at+int64(n) > size {
+			return nil, io.ErrUnexpectedEOF
+		}
+		_, err := f.ReadAt(buf[:n], at)
+		return buf[:n], err
+	}
+	header, err := read(4, offset)
+	if err != nil {
+		return m, err
+	}
+	magic := binary.LittleEndian.Uint32(header)
+	if magic&zstdSkippableMask == zstdSkippableMagic {
+		n, err := read(4, offset+4)
+		if err != nil {
+			return m, err
+		}
+		m.size = 8 + int64(binary.LittleEndian.Uint32(n))
+		return m, nil
+	}
+	if magic != zstdFrameMagic {
+		return m, errors.New("not a zstd frame")
+	}
+	descriptor, err := read(1, offset+4)
+	if err != nil {
+		return m, err
+	}
+	fhd := descriptor[0]
+	singleSegment := fhd&0x20 != 0
+	checksum := fhd&0x04 != 0
+	pos := offset + 5
+	if !singleSegment {
+		// window descriptor
+		pos++
+	}
+	pos += []int64{0, 1, 2, 4}[fhd&3]
+	fcsSize := []int{0, 2, 4, 8}[fhd>>6]
+	if fcsSize == 0 && singleSegment {
+		fcsSize = 1
+	}
+	m.rawSize = -1
+	if fcsSize > 0 {
+		fcs, err := read(fcsSize, pos)
+		if err != nil {
+			return m, err
+		}
+		switch fcsSize {
+		case 1:
+			m.rawSize = int64(fcs[0])
+		case 2:
+			m.rawSize = int64(binary.LittleEndian.Uint16(fcs)) + 256
+		case 4:
+			m.rawSize = int64(binary.LittleEndian.Uint32(fcs))
+		case 8:
+			m.rawSize = int64(binary.LittleEndian.Uint64(fcs))
+		}
+		pos += int64(fcsSize)
+	}
+	// walk the blocks: a 3 byte header with the last block flag, the type
+	// and the size, followed by the block content
+	for {
+		bh, err := read(3, pos)
+		if err != nil {
+			return m, err
+		}
+		h := uint32(bh[0]) | uint32(bh[1])<<8 | uint32(bh[2])<<16
+		last := h&1 != 0
+		blockSize := int64(h >> 3)
+		switch (h >> 1) & 3 {
+		case 1:
+			// RLE blocks hold a single byte repeated blockSize times
+			blockSize = 1
+		case 3:
+			return m, errors.New("reserved block type")
+		}
+		pos += 3 + blockSize
+		if last {
+			break
+		}
+	}
+	if checksum {
+		pos += 4
+	}
+	if pos > size {
+		return m, io.ErrUnexpectedEOF
+	}
+	m.size = pos - offset
+	return m, nil
+}
@@ -0,0 +1,239 @@
+// Package ddsketch summarises numeric values for quantile queries with
+// DDSketch (Masson, Rim and Lee). Every quantile it returns is within a fixed
+// relative error of the true value, and sketches built over different parts
+// of a stream with the same accuracy can be merged exactly. The count, sum,
+// minimum and maximum are tracked exactly alongside the buckets.
+package ddsketch
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DefaultAccuracy is the relative error of the quantiles by default
+const DefaultAccuracy = 0.01
+
+// minIndexable is the smallest magnitude that gets a bucket; values closer
+// to zero are counted as zero
+const minIndexable = 1e-9
+
+// Sketch holds values in logarithmically sized buckets: bucket i covers
+// (gamma^(i-1), gamma^i] where gamma = (1+accuracy)/(1-accuracy).
+type Sketch struct {
+	accuracy float64
+	logGamma float64
+	state    State
+}
+
+// State is everything a sketch knows, in a form that can be sent over the
+// network and loaded with FromState
+type State struct {
+	Count int64
+	Sum   float64
+	Min   float64
+	Max   float64
+	// Zero counts the values near zero; Positive and Negative map bucket
+	// indexes of the magnitudes to counts
+	Zero     int64
+	Positive map[int32]int64
+	Negative map[int32]int64
+}
+
+// New returns an empty sketch whose quantiles are within accuracy (ex. 0.01
+// for 1%) of the true values
+func New(accuracy float64) (*Sketch, error) {
+	if !(accuracy > 0 && accuracy < 1) {
+		return nil, fmt.Errorf("ddsketch accuracy %v out of range (0, 1)", accuracy)
+	}
+	return &Sketch{
+		accuracy: accuracy,
+		logGamma: math.Log((1 + accuracy) / (1 - accuracy)),
+		state: State{
+			Min:      math.Inf(1),
+			Max:      math.Inf(-1),
+			Positive: make(map[int32]int64),
+			Negative: make(map[int32]int64),
+		},
+	}, nil
+}
+
+// FromState rebuilds a sketch from the State of another one with the same
+// accuracy. The bucket counts must not be negative and must add up to Count.
+func FromState(accuracy float64, st State) (*Sketch, error) {
+	s, err := New(accuracy)
+	if err != nil {
+		return nil, err
+	}
+	if st.Zero < 0 {
+		return nil, fmt.Errorf("ddsketch zero bucket holds %d values", st.Zero)
+	}
+	total := st.Zero
+	for _, buckets := range []map[int32]int64{st.Positive, st.Negative} {
+		for i, n := range buckets {
+			if n < 0 {
+				return nil, fmt.Errorf("ddsketch bucket %d holds %d values", i, n)
+			}
+			if n > math.MaxInt64-total {
+				return nil, errors.New("ddsketch buckets hold more values than fit in a count")
+			}
+			total += n
+		}
+	}
+	if total != st.Count {
+		return nil, fmt.Errorf("ddsketch buckets hold %d values, want %d", total, st.Count)
+	}
+	if st.Count == 0 {
+		return s, nil
+	}
+	s.state.Count, s.state.Sum, s.state.Min, s.state.Max, s.state.Zero = st.Count, st.Sum, st.Min, st.Max, st.Zero
+	for i, n := range st.Positive {
+		s.state.Positive[i] = n
+	}
+	for i, n := range st.Negative {
+		s.state.Negative[i] = n
+	}
+	return s, nil
+}
+
+// Accuracy returns the relative error of the quantiles
+func (s *Sketch) Accuracy() float64 {
+	return s.accuracy
+}
+
+// State returns the contents of the sketch. The maps are shared with the sketch.
+func (s *Sketch) State() State {
+	return s.state
+}
+
+// Add records one value
+func (s *Sketch) Add(x float64) {
+	switch {
+	case x > minIndexable:
+		s.state.Positive[s.index(x)]++
+	case x < -minIndexable:
+		s.state.Negative[s.index(-x)]++
+	default:
+		s.state.Zero++
+	}
+	s.state.Count++
+	s.state.Sum += x
+	s.state.Min = min(s.state.Min, x)
+	s.state.Max = max(s.state.Max, x)
+}
+
+// index returns the bucket of a positive magnitude
+func (s *Sketch) index(x float64) int32 {
+	return int32(math.Ceil(math.Log(x) / s.logGamma))
+}
+
+// value returns the representative magnitude of bucket i, which is within
+// the accuracy of every value in the bucket
+func (s *Sketch) value(i int32) float64 {
+	return 2 * math.Exp(float64(i)*s.logGamma) / (1 + math.Exp(s.logGamma))
+}
+
+// Merge adds the values of other into s
+func (s *Sketch) Merge(other *Sketch) error {
+	if s.accuracy != other.accuracy {
+		return fmt.Errorf("cannot merge ddsketches with accuracy %v and %v", s.accuracy, other.accuracy)
+	}
+	if other.state.Count == 0 {
+		return nil
+	}
+	for i, n := range other.state.Positive {
+		s.state.Positive[i] += n
+	}
+	for i, n := range other.state.Negative {
+		s.state.Negative[i] += n
+	}
+	s.state.Zero += other.state.Zero
+	s.state.Count += other.state.Count
+	s.state.Sum += other.state.Sum
+	s.state.Min = min(s.state.Min, other.state.Min)
+	s.state.Max = max(s.state.Max, other.state.Max)
+	return nil
+}
+
+// Count returns the number of values
+func (s *Sketch) Count() int64 {
+	return s.state.Count
+}
+
+// Sum returns the sum of the values
+func (s *Sketch) Sum() float64 {
+	return s.state.Sum
+}
+
+// Min returns the smallest value, or NaN if there are none
+func (s *Sketch) Min() float64 {
+	if s.state.Count == 0 {
+		return math.NaN()
+	}
+	return s.state.Min
+}
+
+// Max returns the largest value, or NaN if there are none
+func (s *Sketch) Max() float64 {
+	if s.state.Count == 0 {
+		return math.NaN()
+	}
+	return s.state.Max
+}
+
+// Mean returns the average of the values, or NaN if there are none
+func (s *Sketch) Mean() float64 {
+	if s.state.Count == 0 {
+		return math.NaN()
+	}
+	return s.state.Sum / float64(s.state.Count)
+}
+
+// Quantile returns an estimate of the q-quantile (0 <= q <= 1) of the
+// values, or NaN if there are none
+func (s *Sketch) Quantile(q float64) float64 {
+	if s.state.Count == 0 || q < 0 || q > 1 {
+		return math.NaN()
+	}
+	rank := q * float64(s.state.Count-1)
+	var seen int64
+	// Walk the buckets from the smallest value up: the largest negative
+	// magnitudes first, then zero, then the positive buckets
+	for _, i := range sortedIndexes(s.state.Negative, true) {
+		seen += s.state.Negative[i]
+		if float64(seen) > rank {
+			return s.clamp(-s.value(i))
+		}
+	}
+	seen += s.state.Zero
+	if float64(seen) > rank {
+		return s.clamp(0)
+	}
+	for _, i := range sortedIndexes(s.state.Positive, false) {
+		seen += s.state.Positive[i]
+		if float64(seen) > rank {
+			return s.clamp(s.value(i))
+		}
+	}
+	return s.state.Max
+}
+
+// clamp keeps an estimate within the exact minimum and maximum
+func (s *Sketch) clamp(x float64) float64 {
+	return min(max(x, s.state.Min), s.state.Max)
+}
+
+func sortedIndexes(buckets map[int32]int64, descending bool) []int32 {
+	indexes := make([]int32, 0, len(buckets))
+	for i := range buckets {
+		indexes = append(indexes, i)
+	}
+	sort.Slice(indexes, func(a, b int) bool {
+		if descending {
+			return indexes[a] > indexes[b]
+		}
+		return indexes[a] < indexes[b]
+	})
+	return indexes
+}
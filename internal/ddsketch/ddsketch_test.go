@@ -0,0 +1,125 @@
+package ddsketch
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func newSketch(t *testing.T, accuracy float64) *Sketch {
+	t.Helper()
+	s, err := New(accuracy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// values returns response-time like values with some zeros and negatives
+func values(n int, seed int64) []float64 {
+	r := rand.New(rand.NewSource(seed))
+	xs := make([]float64, n)
+	for i := range xs {
+		switch i % 20 {
+		case 0:
+			xs[i] = 0
+		case 1:
+			xs[i] = -math.Exp(r.NormFloat64())
+		default:
+			xs[i] = math.Exp(r.NormFloat64() * 2)
+		}
+	}
+	return xs
+}
+
+// checkQuantiles fails unless every quantile of s is within its accuracy of
+// the exact quantile of xs
+func checkQuantiles(t *testing.T, s *Sketch, xs []float64) {
+	t.Helper()
+	sorted := append([]float64(nil), xs...)
+	sort.Float64s(sorted)
+	for _, q := range []float64{0, 0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.95, 0.99, 0.999, 1} {
+		want := sorted[int(q*float64(len(sorted)-1))]
+		got := s.Quantile(q)
+		if math.Abs(got-want) > s.Accuracy()*math.Abs(want)+1e-12 {
+			t.Errorf("q%v = %v, want %v within %v", q, got, want, s.Accuracy())
+		}
+	}
+}
+
+func TestQuantiles(t *testing.T) {
+	for _, accuracy := range []float64{0.01, 0.05} {
+		xs := values(20000, 1)
+		s := newSketch(t, accuracy)
+		var sum float64
+		for _, x := range xs {
+			s.Add(x)
+			sum += x
+		}
+		checkQuantiles(t, s, xs)
+		if s.Count() != int64(len(xs)) || math.Abs(s.Sum()-sum) > 1e-6 {
+			t.Errorf("count %d, sum %v, want %d, %v", s.Count(), s.Sum(), len(xs), sum)
+		}
+		sorted := append([]float64(nil), xs...)
+		sort.Float64s(sorted)
+		if s.Min() != sorted[0] || s.Max() != sorted[len(sorted)-1] {
+			t.Errorf("min %v, max %v, want %v, %v", s.Min(), s.Max(), sorted[0], sorted[len(sorted)-1])
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	xs := values(10000, 2)
+	a, b := newSketch(t, 0.01), newSketch(t, 0.01)
+	for i, x := range xs {
+		if i < len(xs)/3 {
+			a.Add(x)
+		} else {
+			b.Add(x)
+		}
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	checkQuantiles(t, a, xs)
+	if err := a.Merge(newSketch(t, 0.02)); err == nil {
+		t.Error("merged sketches of different accuracies")
+	}
+}
+
+func TestFromState(t *testing.T) {
+	xs := values(1000, 3)
+	s := newSketch(t, 0.01)
+	for _, x := range xs {
+		s.Add(x)
+	}
+	loaded, err := FromState(0.01, s.State())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkQuantiles(t, loaded, xs)
+	for name, st := range map[string]State{
+		"count above the buckets": {Count: 3, Zero: 1, Positive: map[int32]int64{10: 1}},
+		"count below the buckets": {Count: 1, Zero: 1, Positive: map[int32]int64{10: 1}},
+		"negative bucket":         {Count: 1, Positive: map[int32]int64{10: 3, 11: -2}},
+		"negative zero bucket":    {Count: 1, Zero: -1, Negative: map[int32]int64{10: 2}},
+		"overflowing buckets":     {Count: -2, Positive: map[int32]int64{10: math.MaxInt64, 11: math.MaxInt64}},
+	} {
+		if _, err := FromState(0.01, st); err == nil {
+			t.Errorf("%s: loaded an invalid state", name)
+		}
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	s := newSketch(t, 0.01)
+	for name, v := range map[string]float64{"min": s.Min(), "max": s.Max(), "mean": s.Mean(), "q50": s.Quantile(0.5)} {
+		if !math.IsNaN(v) {
+			t.Errorf("%s of an empty sketch = %v, want NaN", name, v)
+		}
+	}
+	if _, err := New(0); err == nil {
+		t.Error("New(0) succeeded")
+	}
+}
@@ -0,0 +1,93 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/query"
+)
+
+var testRecord = &query.Record{
+	IP:          "10.0.0.7",
+	Time:        "10/Oct/2000:13:55:36 -0700",
+	Request:     "GET /api/users?id=1 HTTP/1.1",
+	Status:      "503",
+	Size:        "2326",
+	UserAgent:   "Mozilla/5.0 (X11)",
+	RequestTime: "0.250",
+	Extra:       map[string]string{"upstream": "10.0.1.1:80"},
+}
+
+func hasUpstream(name string) bool { return name == "upstream" }
+
+func TestMatch(t *testing.T) {
+	for src, want := range map[string]bool{
+		"status = 503":                           true,
+		"status != 503":                          false,
+		"status >= 500":                          true,
+		"status < 500":                           false,
+		"size > 1000 and size <= 2326":           true,
+		"request_time between 0.1 and 0.3":       true,
+		"status in (500, 502, 504)":              false,
+		"status_class = 5xx":                     true,
+		"method = GET and path starts with /api": true,
+		`path ends with "id=1"`:                  true,
+		"user_agent contains X11":                true,
+		`user_agent = "Mozilla/5.0 (X11)"`:       true,
+		"ip ~ ^10\\.0\\.0\\.[0-9]$":              true,
+		"not status = 503":                       false,
+		"status = 200 or (method = GET and not path contains admin)": true,
+		"upstream starts with 10.0.1.":                               true,
+		// times are compared as instants, whatever the offset
+		"time >= 2000-10-10T20:55:00Z":                             true,
+		"time between 2000-10-10T13:00:00 and 2000-10-10T14:00:00": false,
+		"time between 2000-10-10T20:00:00 and 2000-10-10T21:00:00": true,
+		// a field that is not a number never matches a numeric comparison
+		"method > 5":  false,
+		"method != 5": true,
+	} {
+		f, err := Compile(src, time.UTC)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", src, err)
+			continue
+		}
+		m, err := New(f, hasUpstream)
+		if err != nil {
+			t.Errorf("New(%q): %v", src, err)
+			continue
+		}
+		if got := m.Match(testRecord); got != want {
+			t.Errorf("%q matched %v, want %v", src, got, want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"status",
+		"status =",
+		"status = 200 and",
+		"(status = 200",
+		"status = 200)",
+		"status between 1",
+		"status in ()",
+		"status ~ (",
+		"time > yesterday",
+		`path = "unterminated`,
+	} {
+		if _, err := Compile(src, time.UTC); err == nil {
+			t.Errorf("Compile(%q) succeeded", src)
+		}
+	}
+}
+
+func TestUnknownField(t *testing.T) {
+	f, err := Compile("nope = 1", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(f, hasUpstream); err == nil {
+		t.Error("New accepted an unknown field")
+	}
+}
@@ -0,0 +1,253 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/query"
+)
+
+// Matcher evaluates a compiled filter against records
+type Matcher struct {
+	root node
+}
+
+// node is a prepared filter node
+type node interface {
+	match(r *query.Record) bool
+}
+
+// New checks a compiled filter and prepares it for evaluation. Fields must be
+// shared fields or accepted by hasExtra, which reports the fields of the log
+// format.
+func New(f *pb.Filter, hasExtra func(name string) bool) (*Matcher, error) {
+	root, err := prepare(f, hasExtra)
+	if err != nil {
+		return nil, err
+	}
+	return &Matcher{root: root}, nil
+}
+
+// Match reports whether a record passes the filter
+func (m *Matcher) Match(r *query.Record) bool {
+	return m.root.match(r)
+}
+
+func prepare(f *pb.Filter, hasExtra func(name string) bool) (node, error) {
+	switch f.Op {
+	case pb.Filter_AND, pb.Filter_OR, pb.Filter_NOT:
+		if len(f.Operands) == 0 || (f.Op == pb.Filter_NOT && len(f.Operands) != 1) {
+			return nil, fmt.Errorf("filter: %s with %d operands", f.Op, len(f.Operands))
+		}
+		operands := make([]node, len(f.Operands))
+		for i, o := range f.Operands {
+			n, err := prepare(o, hasExtra)
+			if err != nil {
+				return nil, err
+			}
+			operands[i] = n
+		}
+		switch f.Op {
+		case pb.Filter_AND:
+			return and(operands), nil
+		case pb.Filter_OR:
+			return or(operands), nil
+		}
+		return notNode{operands[0]}, nil
+	}
+	if !query.HasField(f.Field, hasExtra) {
+		return nil, fmt.Errorf("filter: unknown field %q (want one of %s or a field of the log format)", f.Field, strings.Join(query.Fields, ", "))
+	}
+	want := 1
+	switch f.Op {
+	case pb.Filter_BETWEEN:
+		want = 2
+	case pb.Filter_IN:
+		want = len(f.Values)
+	case pb.Filter_OP_UNSPECIFIED:
+		return nil, fmt.Errorf("filter: missing operation on field %q", f.Field)
+	}
+	if len(f.Values) != want || want == 0 {
+		return nil, fmt.Errorf("filter: %s on field %q with %d values", f.Op, f.Field, len(f.Values))
+	}
+	switch f.Op {
+	case pb.Filter_IN:
+		values := make(map[string]bool, len(f.Values))
+		for _, v := range f.Values {
+			values[v] = true
+		}
+		return inNode{field: f.Field, values: values}, nil
+	case pb.Filter_CONTAINS:
+		return stringNode{field: f.Field, value: f.Values[0], test: strings.Contains}, nil
+	case pb.Filter_PREFIX:
+		return stringNode{field: f.Field, value: f.Values[0], test: strings.HasPrefix}, nil
+	case pb.Filter_SUFFIX:
+		return stringNode{field: f.Field, value: f.Values[0], test: strings.HasSuffix}, nil
+	case pb.Filter_MATCH:
+		re, err := regexp.Compile(f.Values[0])
+		if err != nil {
+			return nil, fmt.Errorf("filter: invalid regular expression for field %q: %v", f.Field, err)
+		}
+		return matchNode{field: f.Field, re: re}, nil
+	}
+	return prepareCompare(f)
+}
+
+// prepareCompare prepares an ordered comparison or equality test
+func prepareCompare(f *pb.Filter) (node, error) {
+	n := compareNode{field: f.Field, op: f.Op, kind: f.Kind, values: f.Values}
+	switch f.Kind {
+	case pb.Filter_NUMBER:
+		for _, v := range f.Values {
+			x, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("filter: invalid number %q for field %q", v, f.Field)
+			}
+			n.numbers = append(n.numbers, x)
+		}
+	case pb.Filter_TIME:
+		if f.Field != query.FieldTime {
+			return nil, fmt.Errorf("filter: time comparison on field %q", f.Field)
+		}
+		for _, v := range f.Values {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return nil, fmt.Errorf("filter: invalid time %q", v)
+			}
+			n.times = append(n.times, t)
+		}
+	case pb.Filter_STRING:
+	default:
+		return nil, fmt.Errorf("filter: unknown kind %s on field %q", f.Kind, f.Field)
+	}
+	return n, nil
+}
+
+type and []node
+
+func (a and) match(r *query.Record) bool {
+	for _, n := range a {
+		if !n.match(r) {
+			return false
+		}
+	}
+	return true
+}
+
+type or []node
+
+func (o or) match(r *query.Record) bool {
+	for _, n := range o {
+		if n.match(r) {
+			return true
+		}
+	}
+	return false
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n notNode) match(r *query.Record) bool {
+	return !n.operand.match(r)
+}
+
+type inNode struct {
+	field  string
+	values map[string]bool
+}
+
+func (n inNode) match(r *query.Record) bool {
+	return n.values[r.Field(n.field)]
+}
+
+type stringNode struct {
+	field string
+	value string
+	test  func(s, value string) bool
+}
+
+func (n stringNode) match(r *query.Record) bool {
+	return n.test(r.Field(n.field), n.value)
+}
+
+type matchNode struct {
+	field string
+	re    *regexp.Regexp
+}
+
+func (n matchNode) match(r *query.Record) bool {
+	return n.re.MatchString(r.Field(n.field))
+}
+
+// compareNode compares a field with one value, or two for between. A field
+// that is not a valid number or time never matches a numeric or time
+// comparison, except for != which it always passes.
+type compareNode struct {
+	field   string
+	op      pb.Filter_Op
+	kind    pb.Filter_Kind
+	values  []string
+	numbers []float64
+	times   []time.Time
+}
+
+func (n compareNode) match(r *query.Record) bool {
+	// cmp holds the comparison of the field with each value: -1, 0 or 1
+	var cmp [2]int
+	switch n.kind {
+	case pb.Filter_NUMBER:
+		x, err := strconv.ParseFloat(r.Field(n.field), 64)
+		if err != nil {
+			return n.op == pb.Filter_NE
+		}
+		for i, v := range n.numbers {
+			cmp[i] = compareFloat(x, v)
+		}
+	case pb.Filter_TIME:
+		t, err := r.Timestamp()
+		if err != nil {
+			return n.op == pb.Filter_NE
+		}
+		for i, v := range n.times {
+			cmp[i] = t.Compare(v)
+		}
+	default:
+		s := r.Field(n.field)
+		for i, v := range n.values {
+			cmp[i] = strings.Compare(s, v)
+		}
+	}
+	switch n.op {
+	case pb.Filter_EQ:
+		return cmp[0] == 0
+	case pb.Filter_NE:
+		return cmp[0] != 0
+	case pb.Filter_LT:
+		return cmp[0] < 0
+	case pb.Filter_LE:
+		return cmp[0] <= 0
+	case pb.Filter_GT:
+		return cmp[0] > 0
+	case pb.Filter_GE:
+		return cmp[0] >= 0
+	case pb.Filter_BETWEEN:
+		return cmp[0] >= 0 && cmp[1] <= 0
+	}
+	return false
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
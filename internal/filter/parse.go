@@ -0,0 +1,401 @@
+// Package filter implements the expressions a job uses to choose which lines
+// it counts, ex.
+//
+//	status >= 500 and path starts with /api and time between 2000-10-10T13:00:00-07:00 and 2000-10-10T14:00:00-07:00
+//
+// Conditions compare a field with =, !=, <, <=, >, >=, ~ (regular expression),
+// between X and Y, in (A, B), contains, starts with and ends with, and are
+// combined with and, or, not and parentheses. Values that contain spaces or
+// any of ()<>=!~, are quoted with double quotes.
+//
+// The master compiles an expression into a pb.Filter tree, which travels in
+// the query of every MapRequest, and the worker turns the tree into a Matcher
+// that is evaluated against the fields of every parsed line.
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	pb "github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/grpc"
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/query"
+)
+
+// timeLayouts are the layouts accepted for time values. Values without an
+// offset are read in the location passed to Compile.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	query.TimeLayout,
+}
+
+// Compile parses an expression and returns its compiled form. Times without
+// an offset are read in loc. Whether the fields exist depends on the log
+// format and is checked by New.
+func Compile(src string, loc *time.Location) (*pb.Filter, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, loc: loc}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+	return f, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return "'" + t.text + "'"
+}
+
+// symbols lists the operators and punctuation, longest first
+var symbols = []string{"==", "!=", "<=", ">=", "!~", "&&", "||", "=", "<", ">", "~", "!", "(", ")", ","}
+
+// tokenize splits an expression into words, quoted strings and symbols
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(src) && src[end] != '"' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("filter: unterminated string at position %d", i+1)
+			}
+			text, err := strconv.Unquote(src[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("filter: invalid string at position %d: %v", i+1, err)
+			}
+			tokens = append(tokens, token{kind: tokString, text: text, pos: i})
+			i = end + 1
+		default:
+			if sym := symbolAt(src, i); sym != "" {
+				tokens = append(tokens, token{kind: tokSymbol, text: sym, pos: i})
+				i += len(sym)
+				continue
+			}
+			end := i
+			for end < len(src) && !unicode.IsSpace(rune(src[end])) && src[end] != '"' && symbolAt(src, end) == "" {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokWord, text: src[i:end], pos: i})
+			i = end
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+// symbolAt returns the symbol starting at src[i], or ""
+func symbolAt(src string, i int) string {
+	for _, sym := range symbols {
+		if strings.HasPrefix(src[i:], sym) {
+			return sym
+		}
+	}
+	return ""
+}
+
+// parser is a recursive descent parser over the tokens of an expression:
+//
+//	or         = and { ("or" | "||") and }
+//	and        = not { ("and" | "&&") not }
+//	not        = ("not" | "!") not | "(" or ")" | comparison
+//	comparison = field ( op value | ["not"] condition )
+//	condition  = "between" value "and" value | "in" "(" value { "," value } ")"
+//	           | "contains" value | "starts" "with" value | "ends" "with" value
+//	           | "matches" value
+type parser struct {
+	tokens []token
+	pos    int
+	loc    *time.Location
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is one of the given keywords or
+// symbols. Keywords are case insensitive and never match quoted strings.
+func (p *parser) accept(texts ...string) bool {
+	t := p.peek()
+	if t.kind != tokWord && t.kind != tokSymbol {
+		return false
+	}
+	for _, text := range texts {
+		if strings.EqualFold(t.text, text) {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		t := p.peek()
+		return p.errorf(t, "expected '%s', got %s", text, t)
+	}
+	return nil
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return fmt.Errorf("filter: %s at position %d", fmt.Sprintf(format, args...), t.pos+1)
+}
+
+func (p *parser) parseOr() (*pb.Filter, error) {
+	return p.parseList(pb.Filter_OR, p.parseAnd, "or", "||")
+}
+
+func (p *parser) parseAnd() (*pb.Filter, error) {
+	return p.parseList(pb.Filter_AND, p.parseNot, "and", "&&")
+}
+
+// parseList parses operands separated by one of the given operators and
+// joins them into a single node when there is more than one
+func (p *parser) parseList(op pb.Filter_Op, operand func() (*pb.Filter, error), operators ...string) (*pb.Filter, error) {
+	f, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	operands := []*pb.Filter{f}
+	for p.accept(operators...) {
+		f, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, f)
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return &pb.Filter{Op: op, Operands: operands}, nil
+}
+
+func (p *parser) parseNot() (*pb.Filter, error) {
+	if p.accept("not", "!") {
+		f, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return not(f), nil
+	}
+	if p.accept("(") {
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	return p.parseComparison()
+}
+
+// comparisonOps maps the comparison symbols to their operation
+var comparisonOps = map[string]pb.Filter_Op{
+	"=":  pb.Filter_EQ,
+	"==": pb.Filter_EQ,
+	"!=": pb.Filter_NE,
+	"<":  pb.Filter_LT,
+	"<=": pb.Filter_LE,
+	">":  pb.Filter_GT,
+	">=": pb.Filter_GE,
+	"~":  pb.Filter_MATCH,
+}
+
+func (p *parser) parseComparison() (*pb.Filter, error) {
+	t := p.next()
+	if t.kind != tokWord {
+		return nil, p.errorf(t, "expected a field name, got %s", t)
+	}
+	field := t.text
+	op := p.peek()
+	if op.kind == tokSymbol {
+		if op.text == "!~" {
+			p.next()
+			f, err := p.leaf(pb.Filter_MATCH, field)
+			if err != nil {
+				return nil, err
+			}
+			return not(f), nil
+		}
+		if o, ok := comparisonOps[op.text]; ok {
+			p.next()
+			return p.leaf(o, field)
+		}
+	}
+	negate := p.accept("not")
+	f, err := p.parseCondition(field)
+	if err != nil {
+		return nil, err
+	}
+	if negate {
+		return not(f), nil
+	}
+	return f, nil
+}
+
+func (p *parser) parseCondition(field string) (*pb.Filter, error) {
+	switch {
+	case p.accept("between"):
+		low, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("and"); err != nil {
+			return nil, err
+		}
+		high, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		return p.typed(&pb.Filter{Op: pb.Filter_BETWEEN, Field: field, Values: []string{low.text, high.text}}, low)
+	case p.accept("in"):
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		f := &pb.Filter{Op: pb.Filter_IN, Field: field}
+		for {
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			f.Values = append(f.Values, v.text)
+			if !p.accept(",") {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return f, nil
+	case p.accept("contains"):
+		return p.leaf(pb.Filter_CONTAINS, field)
+	case p.accept("startswith"):
+		return p.leaf(pb.Filter_PREFIX, field)
+	case p.accept("endswith"):
+		return p.leaf(pb.Filter_SUFFIX, field)
+	case p.accept("matches"):
+		return p.leaf(pb.Filter_MATCH, field)
+	case p.accept("starts"):
+		if err := p.expect("with"); err != nil {
+			return nil, err
+		}
+		return p.leaf(pb.Filter_PREFIX, field)
+	case p.accept("ends"):
+		if err := p.expect("with"); err != nil {
+			return nil, err
+		}
+		return p.leaf(pb.Filter_SUFFIX, field)
+	}
+	t := p.peek()
+	return nil, p.errorf(t, "expected a comparison after %q, got %s", field, t)
+}
+
+// leaf parses a value and returns a comparison of field with it
+func (p *parser) leaf(op pb.Filter_Op, field string) (*pb.Filter, error) {
+	v, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	return p.typed(&pb.Filter{Op: op, Field: field, Values: []string{v.text}}, v)
+}
+
+// typed decides how an ordered comparison treats its values: as times for
+// the time field, as numbers when every value is one, and as strings
+// otherwise. Time values are normalised to RFC 3339.
+func (p *parser) typed(f *pb.Filter, at token) (*pb.Filter, error) {
+	switch f.Op {
+	case pb.Filter_EQ, pb.Filter_NE, pb.Filter_LT, pb.Filter_LE, pb.Filter_GT, pb.Filter_GE, pb.Filter_BETWEEN:
+	default:
+		return f, nil
+	}
+	if f.Field == query.FieldTime {
+		for i, v := range f.Values {
+			t, err := parseTime(v, p.loc)
+			if err != nil {
+				return nil, p.errorf(at, "%v", err)
+			}
+			f.Values[i] = t.Format(time.RFC3339Nano)
+		}
+		f.Kind = pb.Filter_TIME
+		return f, nil
+	}
+	for _, v := range f.Values {
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			return f, nil
+		}
+	}
+	f.Kind = pb.Filter_NUMBER
+	return f, nil
+}
+
+// value returns the next token if it is a word or quoted string
+func (p *parser) value() (token, error) {
+	t := p.next()
+	if t.kind != tokWord && t.kind != tokString {
+		return t, p.errorf(t, "expected a value, got %s", t)
+	}
+	return t, nil
+}
+
+// parseTime reads a time value in one of timeLayouts
+func parseTime(value string, loc *time.Location) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: want RFC 3339 (ex. 2000-10-10T13:55:36-07:00), a date or %s", value, query.TimeLayout)
+}
+
+func not(f *pb.Filter) *pb.Filter {
+	return &pb.Filter{Op: pb.Filter_NOT, Operands: []*pb.Filter{f}}
+}
@@ -19,8 +19,12 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	MapReduceService_ProcessMap_FullMethodName    = "/mapreduce.MapReduceService/ProcessMap"
-	MapReduceService_ProcessReduce_FullMethodName = "/mapreduce.MapReduceService/ProcessReduce"
+	MapReduceService_ProcessMap_FullMethodName          = "/mapreduce.MapReduceService/ProcessMap"
+	MapReduceService_ProcessMapStream_FullMethodName    = "/mapreduce.MapReduceService/ProcessMapStream"
+	MapReduceService_ProcessReduce_FullMethodName       = "/mapreduce.MapReduceService/ProcessReduce"
+	MapReduceService_ProcessReduceStream_FullMethodName = "/mapreduce.MapReduceService/ProcessReduceStream"
+	MapReduceService_RegisterWorker_FullMethodName      = "/mapreduce.MapReduceService/RegisterWorker"
+	MapReduceService_Heartbeat_FullMethodName           = "/mapreduce.MapReduceService/Heartbeat"
 )
 
 // MapReduceServiceClient is the client API for MapReduceService service.
@@ -30,7 +34,16 @@ const (
 // Define the gRPC service
 type MapReduceServiceClient interface {
 	ProcessMap(ctx context.Context, in *MapRequest, opts ...grpc.CallOption) (*MapResponse, error)
+	// Streams a chunk as line-aligned frames. The first frame carries the
+	// job settings, later frames only carry log_data.
+	ProcessMapStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[MapRequest, MapResponse], error)
 	ProcessReduce(ctx context.Context, in *ReduceRequest, opts ...grpc.CallOption) (*ReduceResponse, error)
+	// Streams a partition as batches of partial results, which the worker
+	// sums into one set of results. Only the first batch needs partition and job_id.
+	ProcessReduceStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ReduceRequest, ReduceResponse], error)
+	// Served by the master: workers announce themselves and stay alive
+	RegisterWorker(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error)
+	Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error)
 }
 
 type mapReduceServiceClient struct {
@@ -51,6 +64,19 @@ func (c *mapReduceServiceClient) ProcessMap(ctx context.Context, in *MapRequest,
 	return out, nil
 }
 
+func (c *mapReduceServiceClient) ProcessMapStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[MapRequest, MapResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MapReduceService_ServiceDesc.Streams[0], MapReduceService_ProcessMapStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[MapRequest, MapResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MapReduceService_ProcessMapStreamClient = grpc.ClientStreamingClient[MapRequest, MapResponse]
+
 func (c *mapReduceServiceClient) ProcessReduce(ctx context.Context, in *ReduceRequest, opts ...grpc.CallOption) (*ReduceResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(ReduceResponse)
@@ -61,6 +87,39 @@ func (c *mapReduceServiceClient) ProcessReduce(ctx context.Context, in *ReduceRe
 	return out, nil
 }
 
+func (c *mapReduceServiceClient) ProcessReduceStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ReduceRequest, ReduceResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MapReduceService_ServiceDesc.Streams[1], MapReduceService_ProcessReduceStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ReduceRequest, ReduceResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MapReduceService_ProcessReduceStreamClient = grpc.ClientStreamingClient[ReduceRequest, ReduceResponse]
+
+func (c *mapReduceServiceClient) RegisterWorker(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RegisterResponse)
+	err := c.cc.Invoke(ctx, MapReduceService_RegisterWorker_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mapReduceServiceClient) Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HeartbeatResponse)
+	err := c.cc.Invoke(ctx, MapReduceService_Heartbeat_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // MapReduceServiceServer is the server API for MapReduceService service.
 // All implementations must embed UnimplementedMapReduceServiceServer
 // for forward compatibility.
@@ -68,7 +127,16 @@ func (c *mapReduceServiceClient) ProcessReduce(ctx context.Context, in *ReduceRe
 // Define the gRPC service
 type MapReduceServiceServer interface {
 	ProcessMap(context.Context, *MapRequest) (*MapResponse, error)
+	// Streams a chunk as line-aligned frames. The first frame carries the
+	// job settings, later frames only carry log_data.
+	ProcessMapStream(grpc.ClientStreamingServer[MapRequest, MapResponse]) error
 	ProcessReduce(context.Context, *ReduceRequest) (*ReduceResponse, error)
+	// Streams a partition as batches of partial results, which the worker
+	// sums into one set of results. Only the first batch needs partition and job_id.
+	ProcessReduceStream(grpc.ClientStreamingServer[ReduceRequest, ReduceResponse]) error
+	// Served by the master: workers announce themselves and stay alive
+	RegisterWorker(context.Context, *RegisterRequest) (*RegisterResponse, error)
+	Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error)
 	mustEmbedUnimplementedMapReduceServiceServer()
 }
 
@@ -82,9 +150,21 @@ type UnimplementedMapReduceServiceServer struct{}
 func (UnimplementedMapReduceServiceServer) ProcessMap(context.Context, *MapRequest) (*MapResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ProcessMap not implemented")
 }
+func (UnimplementedMapReduceServiceServer) ProcessMapStream(grpc.ClientStreamingServer[MapRequest, MapResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ProcessMapStream not implemented")
+}
 func (UnimplementedMapReduceServiceServer) ProcessReduce(context.Context, *ReduceRequest) (*ReduceResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ProcessReduce not implemented")
 }
+func (UnimplementedMapReduceServiceServer) ProcessReduceStream(grpc.ClientStreamingServer[ReduceRequest, ReduceResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ProcessReduceStream not implemented")
+}
+func (UnimplementedMapReduceServiceServer) RegisterWorker(context.Context, *RegisterRequest) (*RegisterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterWorker not implemented")
+}
+func (UnimplementedMapReduceServiceServer) Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Heartbeat not implemented")
+}
 func (UnimplementedMapReduceServiceServer) mustEmbedUnimplementedMapReduceServiceServer() {}
 func (UnimplementedMapReduceServiceServer) testEmbeddedByValue()                          {}
 
@@ -124,6 +204,13 @@ func _MapReduceService_ProcessMap_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MapReduceService_ProcessMapStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MapReduceServiceServer).ProcessMapStream(&grpc.GenericServerStream[MapRequest, MapResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MapReduceService_ProcessMapStreamServer = grpc.ClientStreamingServer[MapRequest, MapResponse]
+
 func _MapReduceService_ProcessReduce_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ReduceRequest)
 	if err := dec(in); err != nil {
@@ -142,6 +229,49 @@ func _MapReduceService_ProcessReduce_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MapReduceService_ProcessReduceStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MapReduceServiceServer).ProcessReduceStream(&grpc.GenericServerStream[ReduceRequest, ReduceResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MapReduceService_ProcessReduceStreamServer = grpc.ClientStreamingServer[ReduceRequest, ReduceResponse]
+
+func _MapReduceService_RegisterWorker_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MapReduceServiceServer).RegisterWorker(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MapReduceService_RegisterWorker_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MapReduceServiceServer).RegisterWorker(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MapReduceService_Heartbeat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HeartbeatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MapReduceServiceServer).Heartbeat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MapReduceService_Heartbeat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MapReduceServiceServer).Heartbeat(ctx, req.(*HeartbeatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // MapReduceService_ServiceDesc is the grpc.ServiceDesc for MapReduceService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -157,7 +287,26 @@ var MapReduceService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ProcessReduce",
 			Handler:    _MapReduceService_ProcessReduce_Handler,
 		},
+		{
+			MethodName: "RegisterWorker",
+			Handler:    _MapReduceService_RegisterWorker_Handler,
+		},
+		{
+			MethodName: "Heartbeat",
+			Handler:    _MapReduceService_Heartbeat_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ProcessMapStream",
+			Handler:       _MapReduceService_ProcessMapStream_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ProcessReduceStream",
+			Handler:       _MapReduceService_ProcessReduceStream_Handler,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/node.proto",
 }
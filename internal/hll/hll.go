@@ -0,0 +1,204 @@
+// Package hll estimates the number of distinct values in a stream with
+// HyperLogLog (Flajolet et al.). Sketches built over different parts of a
+// stream with the same precision can be merged, and merging a sketch twice
+// does not change the estimate.
+package hll
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// Precision bounds. A sketch with precision p has 2^p registers and a
+// standard error of about 1.04/sqrt(2^p).
+const (
+	MinPrecision     = 4
+	MaxPrecision     = 18
+	DefaultPrecision = 14
+)
+
+// Sketch is a HyperLogLog sketch. It starts sparse, storing only the
+// registers that were set, and switches to one byte per register once that
+// would take less memory. Small groups therefore stay cheap.
+type Sketch struct {
+	p uint8
+	// registers holds every register once the sketch is dense
+	registers []uint8
+	// sparse maps register index to value while the sketch is sparse
+	sparse map[uint32]uint8
+}
+
+// New returns an empty sketch with 2^precision registers
+func New(precision int) (*Sketch, error) {
+	if precision < MinPrecision || precision > MaxPrecision {
+		return nil, fmt.Errorf("hyperloglog precision %d out of range [%d, %d]", precision, MinPrecision, MaxPrecision)
+	}
+	return &Sketch{p: uint8(precision), sparse: make(map[uint32]uint8)}, nil
+}
+
+// Load rebuilds a sketch from its Registers or its Sparse entries, ex. after
+// they were sent over the network
+func Load(precision int, registers []byte, sparse []uint32) (*Sketch, error) {
+	s, err := New(precision)
+	if err != nil {
+		return nil, err
+	}
+	if registers != nil {
+		if len(registers) != s.size() {
+			return nil, fmt.Errorf("hyperloglog with precision %d has %d registers, got %d", precision, s.size(), len(registers))
+		}
+		s.registers = append([]uint8(nil), registers...)
+		s.sparse = nil
+		return s, nil
+	}
+	for _, e := range sparse {
+		idx, rank := e>>8, uint8(e)
+		if idx >= uint32(s.size()) {
+			return nil, fmt.Errorf("hyperloglog register %d out of range for precision %d", idx, precision)
+		}
+		s.set(idx, rank)
+	}
+	return s, nil
+}
+
+// Precision returns the precision of the sketch
+func (s *Sketch) Precision() int {
+	return int(s.p)
+}
+
+// size returns the number of registers
+func (s *Sketch) size() int {
+	return 1 << s.p
+}
+
+// Add records one occurrence of value
+func (s *Sketch) Add(value string) {
+	h := hash(value)
+	idx := uint32(h >> (64 - s.p))
+	// the rank is the position of the first set bit after the index bits;
+	// the sentinel bit caps it when every remaining bit is zero
+	rank := uint8(bits.LeadingZeros64(h<<s.p|1<<(s.p-1)) + 1)
+	s.set(idx, rank)
+}
+
+// set raises register idx to rank
+func (s *Sketch) set(idx uint32, rank uint8) {
+	if s.registers != nil {
+		s.registers[idx] = max(s.registers[idx], rank)
+		return
+	}
+	if rank > s.sparse[idx] {
+		s.sparse[idx] = rank
+	}
+	// a map entry costs several bytes, switch to dense well before the
+	// sparse form gets as large as the registers
+	if len(s.sparse) > s.size()/16 {
+		s.registers = make([]uint8, s.size())
+		for i, r := range s.sparse {
+			s.registers[i] = r
+		}
+		s.sparse = nil
+	}
+}
+
+// Merge adds the values seen by other into s
+func (s *Sketch) Merge(other *Sketch) error {
+	if s.p != other.p {
+		return fmt.Errorf("cannot merge hyperloglog sketches with precision %d and %d", s.p, other.p)
+	}
+	if other.registers != nil {
+		for i, r := range other.registers {
+			if r > 0 {
+				s.set(uint32(i), r)
+			}
+		}
+		return nil
+	}
+	for i, r := range other.sparse {
+		s.set(i, r)
+	}
+	return nil
+}
+
+// Estimate returns the estimated number of distinct values
+func (s *Sketch) Estimate() uint64 {
+	m := float64(s.size())
+	var (
+		sum   float64
+		zeros int
+	)
+	if s.registers != nil {
+		for _, r := range s.registers {
+			sum += math.Ldexp(1, -int(r))
+			if r == 0 {
+				zeros++
+			}
+		}
+	} else {
+		zeros = s.size() - len(s.sparse)
+		sum = float64(zeros)
+		for _, r := range s.sparse {
+			sum += math.Ldexp(1, -int(r))
+		}
+	}
+	estimate := alpha(m) * m * m / sum
+	// Linear counting is more accurate while many registers are still empty
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// Registers returns one byte per register, or nil while the sketch is sparse
+func (s *Sketch) Registers() []byte {
+	return s.registers
+}
+
+// Sparse returns the registers that are set as index<<8 | value, or nil once
+// the sketch is dense
+func (s *Sketch) Sparse() []uint32 {
+	if s.registers != nil {
+		return nil
+	}
+	entries := make([]uint32, 0, len(s.sparse))
+	for i, r := range s.sparse {
+		entries = append(entries, i<<8|uint32(r))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i] < entries[j] })
+	return entries
+}
+
+// StdError returns the relative standard error of a sketch with the given precision
+func StdError(precision int) float64 {
+	return 1.04 / math.Sqrt(float64(int(1)<<precision))
+}
+
+func alpha(m float64) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	}
+	return 0.7213 / (1 + 1.079/m)
+}
+
+// hash returns a 64 bit hash of value that is the same on every machine.
+// FNV alone spreads similar values such as IP addresses poorly, so its
+// output is run through the MurmurHash3 finalizer.
+func hash(value string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(value))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,98 @@
+package hll
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func newSketch(t *testing.T, precision int) *Sketch {
+	t.Helper()
+	s, err := New(precision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// checkEstimate fails if the estimate is further from n than four standard
+// errors, or off at all for small counts
+func checkEstimate(t *testing.T, s *Sketch, n int) {
+	t.Helper()
+	got := float64(s.Estimate())
+	tolerance := 4 * StdError(s.Precision()) * float64(n)
+	if n <= 100 {
+		tolerance = 1
+	}
+	if math.Abs(got-float64(n)) > tolerance {
+		t.Errorf("estimate %v for %d distinct values, want within %v", got, n, tolerance)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	for _, n := range []int{0, 1, 10, 100, 1000, 50000, 500000} {
+		s := newSketch(t, DefaultPrecision)
+		for i := 0; i < n; i++ {
+			// every value twice, duplicates must not count
+			s.Add(fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+			s.Add(fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+		}
+		checkEstimate(t, s, n)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, b := newSketch(t, 12), newSketch(t, 12)
+	for i := 0; i < 30000; i++ {
+		a.Add(fmt.Sprint(i))
+		b.Add(fmt.Sprint(i + 20000))
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	checkEstimate(t, a, 50000)
+	// merging again does not change the estimate
+	before := a.Estimate()
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if a.Estimate() != before {
+		t.Errorf("estimate changed from %d to %d after merging twice", before, a.Estimate())
+	}
+	if err := a.Merge(newSketch(t, 13)); err == nil {
+		t.Error("merged sketches of different precisions")
+	}
+}
+
+func TestLoadRoundTrip(t *testing.T) {
+	for _, n := range []int{50, 20000} {
+		s := newSketch(t, 10)
+		for i := 0; i < n; i++ {
+			s.Add(fmt.Sprint(i))
+		}
+		if (s.Registers() == nil) == (s.Sparse() == nil) {
+			t.Fatalf("%d values: want exactly one of Registers and Sparse", n)
+		}
+		loaded, err := Load(s.Precision(), s.Registers(), s.Sparse())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loaded.Estimate() != s.Estimate() {
+			t.Errorf("%d values: loaded estimate %d, want %d", n, loaded.Estimate(), s.Estimate())
+		}
+	}
+	if _, err := Load(10, make([]byte, 5), nil); err == nil {
+		t.Error("loaded registers of the wrong size")
+	}
+	if _, err := Load(4, nil, []uint32{16 << 8}); err == nil {
+		t.Error("loaded a sparse register out of range")
+	}
+}
+
+func TestPrecisionBounds(t *testing.T) {
+	for _, p := range []int{MinPrecision - 1, MaxPrecision + 1} {
+		if _, err := New(p); err == nil {
+			t.Errorf("New(%d) succeeded", p)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package logformat
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/query"
+)
+
+// AWS load balancer access logs. Application Load Balancer entries start
+// with the request type, ex.
+//
+//	http 2018-07-02T22:23:00.186641Z app/my-loadbalancer/50dc6c495c0c9188 192.168.131.39:2817 10.0.0.1:80 0.000 0.001 0.000 200 200 34 366 "GET http://www.example.com:80/ HTTP/1.1" "curl/7.46.0" - - arn:aws:elasticloadbalancing:... "Root=1-58337262-36d228ad5d99923122bbe354" "-" "-" 0 2018-07-02T22:22:48.364000Z "forward" "-" "-" "10.0.0.1:80" "200" "-" "-"
+//
+// while Classic Load Balancer entries start with the time, ex.
+//
+//	2015-05-13T23:39:43.945958Z my-loadbalancer 192.168.131.39:2817 10.0.0.1:80 0.000073 0.001048 0.000057 200 200 0 29 "GET http://www.example.com:80/ HTTP/1.1" "curl/7.38.0" - -
+var albFormat = &Format{
+	Name:        "alb",
+	Description: "AWS Application Load Balancer access log",
+	Fields: []string{
+		"type", "elb", "target", "request_processing_time", "target_processing_time",
+		"response_processing_time", "target_status", "received_bytes", "url",
+		"ssl_cipher", "ssl_protocol", "target_group_arn", "trace_id", "domain_name",
+		"actions_executed", "error_reason",
+	},
+	New: func(string) Parser { return awsParser{alb: true} },
+}
+
+var elbFormat = &Format{
+	Name:        "elb",
+	Description: "AWS Classic Load Balancer access log",
+	Fields: []string{
+		"elb", "target", "request_processing_time", "target_processing_time",
+		"response_processing_time", "target_status", "received_bytes", "url",
+		"ssl_cipher", "ssl_protocol",
+	},
+	New: func(string) Parser { return awsParser{} },
+}
+
+// albTypes are the request types an ALB entry can start with
+var albTypes = map[string]bool{"http": true, "https": true, "h2": true, "grpcs": true, "ws": true, "wss": true}
+
+// awsParser parses both load balancer formats, which share every column
+// after the ALB type
+type awsParser struct {
+	alb bool
+}
+
+func (p awsParser) Parse(line string, r *query.Record) Result {
+	cols := splitQuoted(line)
+	if p.alb {
+		if len(cols) < 25 || !albTypes[cols[0]] {
+			return Rejected
+		}
+		r.SetExtra("type", cols[0])
+		cols = cols[1:]
+	} else if len(cols) < 15 {
+		return Rejected
+	}
+	if _, err := time.Parse(time.RFC3339Nano, cols[0]); err != nil {
+		return Rejected
+	}
+	r.Time = cols[0]
+	r.TimeLayout = time.RFC3339Nano
+	r.SetExtra("elb", cols[1])
+	r.IP = hostOnly(cols[2])
+	r.SetExtra("target", dash(cols[3]))
+	// the processing times are -1 when the request never reached a target
+	var total float64
+	complete := true
+	for i, name := range []string{"request_processing_time", "target_processing_time", "response_processing_time"} {
+		r.SetExtra(name, cols[4+i])
+		t, err := strconv.ParseFloat(cols[4+i], 64)
+		if err != nil || t < 0 {
+			complete = false
+			continue
+		}
+		total += t
+	}
+	if complete {
+		r.RequestTime = strconv.FormatFloat(total, 'f', -1, 64)
+	}
+	r.Status = dash(cols[7])
+	r.SetExtra("target_status", dash(cols[8]))
+	r.SetExtra("received_bytes", cols[9])
+	r.Size = cols[10]
+	r.Request = awsRequest(cols[11], r)
+	r.UserAgent = dash(cols[12])
+	r.SetExtra("ssl_cipher", dash(cols[13]))
+	r.SetExtra("ssl_protocol", dash(cols[14]))
+	if p.alb {
+		r.SetExtra("target_group_arn", dash(cols[15]))
+		r.SetExtra("trace_id", dash(cols[16]))
+		r.SetExtra("domain_name", dash(cols[17]))
+		r.SetExtra("actions_executed", dash(cols[21]))
+		r.SetExtra("error_reason", dash(cols[23]))
+	}
+	return Matched
+}
+
+// awsRequest turns "GET http://host:80/path?q HTTP/1.1" into
+// "GET /path?q HTTP/1.1" and keeps the full URL in the url field
+func awsRequest(request string, r *query.Record) string {
+	parts := strings.SplitN(request, " ", 3)
+	if len(parts) != 3 {
+		return request
+	}
+	r.SetExtra("url", parts[1])
+	path := parts[1]
+	if i := strings.Index(path, "://"); i >= 0 {
+		path = path[i+3:]
+		if j := strings.IndexByte(path, '/'); j >= 0 {
+			path = path[j:]
+		} else {
+			path = "/"
+		}
+	}
+	return parts[0] + " " + path + " " + parts[2]
+}
+
+// hostOnly strips the port from an ip:port pair
+func hostOnly(addr string) string {
+	if i := strings.LastIndexByte(addr, ':'); i >= 0 && !strings.HasSuffix(addr, "]") {
+		return strings.Trim(addr[:i], "[]")
+	}
+	return addr
+}
+
+// splitQuoted splits a line on spaces, keeping double quoted values together
+// and removing the quotes
+func splitQuoted(line string) []string {
+	var cols []string
+	for len(line) > 0 {
+		if line[0] == ' ' {
+			line = line[1:]
+			continue
+		}
+		if line[0] == '"' {
+			end := strings.IndexByte(line[1:], '"')
+			if end < 0 {
+				cols = append(cols, line[1:])
+				break
+			}
+			cols = append(cols, line[1:end+1])
+			line = line[end+2:]
+			continue
+		}
+		end := strings.IndexByte(line, ' ')
+		if end < 0 {
+			cols = append(cols, line)
+			break
+		}
+		cols = append(cols, line[:end])
+		line = line[end:]
+	}
+	return cols
+}
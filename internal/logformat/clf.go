@@ -0,0 +1,82 @@
+package logformat
+
+import (
+	"regexp"
+
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/query"
+)
+
+// Apache/NGINX access logs, ex.
+//
+//	127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08" 0.012
+//
+// common stops after the size, combined adds referrer and user agent (and
+// tolerates anything after them), and nginx adds $request_time and
+// optionally $upstream_response_time.
+const clfPrefix = `^(\S+) \S+ (\S+) \[([^\]]+)\] "([^"]*)" (\d{3}) (\d+|-)`
+
+var (
+	commonRegex   = regexp.MustCompile(clfPrefix + `$`)
+	combinedRegex = regexp.MustCompile(clfPrefix + ` "([^"]*)" "([^"]*)"(?: .*)?$`)
+	nginxRegex    = regexp.MustCompile(clfPrefix + ` "([^"]*)" "([^"]*)" (\d+(?:\.\d+)?|-)(?: (\S+))?$`)
+)
+
+var commonFormat = &Format{
+	Name:        "common",
+	Description: "NCSA common log format",
+	Fields:      []string{"user"},
+	New: func(string) Parser {
+		return &regexParser{match: commonRegex.FindStringSubmatch, fill: fillCommon}
+	},
+}
+
+var combinedFormat = &Format{
+	Name:        "combined",
+	Description: "Apache/NGINX combined log format",
+	Fields:      []string{"user"},
+	New: func(string) Parser {
+		return &regexParser{match: combinedRegex.FindStringSubmatch, fill: fillCombined}
+	},
+}
+
+var nginxFormat = &Format{
+	Name:        "nginx",
+	Description: "NGINX combined log format followed by $request_time [$upstream_response_time]",
+	Fields:      []string{"user", "upstream_response_time"},
+	New: func(string) Parser {
+		return &regexParser{match: nginxRegex.FindStringSubmatch, fill: fillNginx}
+	},
+}
+
+func fillCommon(m []string, r *query.Record) {
+	r.IP = m[1]
+	if m[2] != "-" {
+		r.SetExtra("user", m[2])
+	}
+	r.Time = m[3]
+	r.Request = m[4]
+	r.Status = m[5]
+	r.Size = dash(m[6])
+}
+
+func fillCombined(m []string, r *query.Record) {
+	fillCommon(m, r)
+	r.Referrer = m[7]
+	r.UserAgent = m[8]
+}
+
+func fillNginx(m []string, r *query.Record) {
+	fillCombined(m, r)
+	r.RequestTime = dash(m[9])
+	if upstream := dash(m[10]); upstream != "" {
+		r.SetExtra("upstream_response_time", upstream)
+	}
+}
+
+// dash turns the "-" placeholder for a missing value into ""
+func dash(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
@@ -0,0 +1,109 @@
+package logformat
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/query"
+)
+
+// JSON lines, one object per line, ex.
+//
+//	{"remote_addr":"10.0.0.1","time":"2000-10-10T13:55:36Z","method":"GET","path":"/","status":200,"bytes":512}
+//
+// Well known keys are mapped onto the shared fields and every top level key
+// is also available under its own name.
+var jsonFormat = &Format{
+	Name:        "json",
+	Description: "JSON lines with one object per line",
+	AnyField:    true,
+	New:         func(string) Parser { return jsonParser{} },
+}
+
+// jsonAliases maps shared fields to the keys they are commonly logged under,
+// in order of preference
+var jsonAliases = []struct {
+	field string
+	keys  []string
+}{
+	{query.FieldIP, []string{"ip", "remote_addr", "client_ip", "clientip", "remote_ip"}},
+	{query.FieldTime, []string{"time", "timestamp", "@timestamp", "ts", "time_local"}},
+	{query.FieldRequest, []string{"request"}},
+	{query.FieldMethod, []string{"method", "request_method", "verb"}},
+	{query.FieldPath, []string{"path", "uri", "request_uri", "url"}},
+	{query.FieldProtocol, []string{"protocol", "server_protocol", "http_version"}},
+	{query.FieldStatus, []string{"status", "status_code", "response"}},
+	{query.FieldSize, []string{"size", "bytes", "body_bytes_sent", "bytes_sent"}},
+	{query.FieldReferrer, []string{"referrer", "referer", "http_referer"}},
+	{query.FieldUserAgent, []string{"user_agent", "http_user_agent", "agent"}},
+	{query.FieldRequestTime, []string{"request_time", "duration", "latency"}},
+}
+
+type jsonParser struct{}
+
+func (jsonParser) Parse(line string, r *query.Record) Result {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "{") {
+		return Rejected
+	}
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(line), &obj); err != nil {
+		return Rejected
+	}
+	for key, value := range obj {
+		r.SetExtra(key, jsonString(value))
+	}
+	values := make(map[string]string, len(jsonAliases))
+	for _, alias := range jsonAliases {
+		for _, key := range alias.keys {
+			if v, ok := r.Extra[key]; ok {
+				values[alias.field] = v
+				break
+			}
+		}
+	}
+	r.IP = values[query.FieldIP]
+	r.Status = values[query.FieldStatus]
+	r.Size = values[query.FieldSize]
+	r.Referrer = values[query.FieldReferrer]
+	r.UserAgent = values[query.FieldUserAgent]
+	r.RequestTime = values[query.FieldRequestTime]
+	r.Request = values[query.FieldRequest]
+	if r.Request == "" && values[query.FieldMethod] != "" {
+		r.Request = strings.TrimSpace(values[query.FieldMethod] + " " + values[query.FieldPath] + " " + values[query.FieldProtocol])
+	}
+	r.Time = values[query.FieldTime]
+	r.TimeLayout = jsonTimeLayout(r.Time)
+	return Matched
+}
+
+// jsonString renders a decoded JSON value as a field value. Numbers keep
+// their shortest form so that a status of 200 becomes "200".
+func jsonString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// jsonTimeLayout guesses the layout of a JSON time value: RFC 3339, seconds
+// since the epoch, or the common log layout.
+func jsonTimeLayout(s string) string {
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return query.TimeLayoutUnix
+	}
+	if _, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return time.RFC3339Nano
+	}
+	return query.TimeLayout
+}
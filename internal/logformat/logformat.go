@@ -0,0 +1,236 @@
+// Package logformat holds the registry of log line parsers used by the
+// worker. Each format turns a line into a query.Record; the master uses the
+// same registry to validate a job and to detect the format of a file from a
+// sample of its first chunk.
+package logformat
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/query"
+)
+
+// Auto asks the master to detect the format from the first chunk
+const Auto = "auto"
+
+// Default is used when a MapRequest does not name a format
+const Default = "combined"
+
+// Result is the outcome of parsing a single line
+type Result int
+
+const (
+	// Rejected means the line is not in the expected format
+	Rejected Result = iota
+	// Matched means the fields of the line were extracted
+	Matched
+	// Skipped means the line carries no data, ex. a W3C #Fields directive
+	Skipped
+)
+
+// Parser parses the lines of one chunk. A parser may keep state between
+// lines, so every chunk gets its own parser.
+type Parser interface {
+	Parse(line string, r *query.Record) Result
+}
+
+// HeaderParser is implemented by parsers that depend on header lines, such
+// as the W3C #Fields directive. The master passes the header found in the
+// first chunk to every other chunk so they can be parsed on their own.
+type HeaderParser interface {
+	Parser
+	Header() string
+}
+
+// Format describes a log format
+type Format struct {
+	// Name selects the format in MapRequest.format and the -format flag
+	Name        string
+	Description string
+	// Fields lists the format specific fields stored in query.Record.Extra
+	Fields []string
+	// AnyField is set when the format can carry arbitrary fields, ex. JSON
+	AnyField bool
+	// New returns a parser for one chunk, primed with the header lines
+	// captured from the first chunk (if any)
+	New func(header string) Parser
+}
+
+// HasField reports whether name is a shared field or a field of the format
+func (f *Format) HasField(name string) bool {
+	if query.IsField(name) || f.AnyField {
+		return true
+	}
+	for _, field := range f.Fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	mu       sync.RWMutex
+	registry = make(map[string]*Format)
+	// order is the detection priority: more specific formats come first so
+	// that, for example, an NGINX line is not detected as plain combined
+	order []string
+)
+
+func init() {
+	for _, f := range []*Format{
+		albFormat,
+		elbFormat,
+		w3cFormat,
+		syslogFormat,
+		jsonFormat,
+		nginxFormat,
+		combinedFormat,
+		commonFormat,
+	} {
+		Register(f)
+	}
+}
+
+// Register adds a format to the registry. Formats registered later are tried
+// last when detecting.
+func Register(f *Format) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registry[f.Name]; !ok {
+		order = append(order, f.Name)
+	}
+	registry[f.Name] = f
+}
+
+// Lookup returns the named format
+func Lookup(name string) (*Format, error) {
+	if name == "" {
+		name = Default
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	f, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown log format %q (want %s or one of %s)", name, Auto, strings.Join(namesLocked(), ", "))
+	}
+	return f, nil
+}
+
+// Names returns the names of every registered format, sorted
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return namesLocked()
+}
+
+func namesLocked() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sampleLines is how many lines of the first chunk are used for detection
+const sampleLines = 200
+
+// Detect picks the format that parses the most lines in a sample of the
+// data. At least half of the data lines must parse. It also returns the
+// header captured while parsing the sample.
+func Detect(data []byte) (*Format, string, error) {
+	sample := sample(data)
+	mu.RLock()
+	formats := make([]*Format, len(order))
+	for i, name := range order {
+		formats[i] = registry[name]
+	}
+	mu.RUnlock()
+
+	var (
+		best       *Format
+		bestHeader string
+		bestCount  int
+		dataLines  int
+	)
+	for _, f := range formats {
+		p := f.New("")
+		matched, skipped := 0, 0
+		for _, line := range sample {
+			var r query.Record
+			switch p.Parse(line, &r) {
+			case Matched:
+				matched++
+			case Skipped:
+				skipped++
+			}
+		}
+		// ties go to the format registered first
+		if matched > bestCount {
+			best, bestCount = f, matched
+			dataLines = len(sample) - skipped
+			bestHeader = ""
+			if hp, ok := p.(HeaderParser); ok {
+				bestHeader = hp.Header()
+			}
+		}
+	}
+	if best == nil || bestCount*2 < dataLines {
+		return nil, "", fmt.Errorf("could not detect the log format from %d sample lines", len(sample))
+	}
+	return best, bestHeader, nil
+}
+
+// SampleHeader returns the header the format captures from the data, so an
+// explicitly chosen format gets the same treatment as a detected one.
+func SampleHeader(f *Format, data []byte) string {
+	p := f.New("")
+	hp, ok := p.(HeaderParser)
+	if !ok {
+		return ""
+	}
+	for _, line := range sample(data) {
+		var r query.Record
+		p.Parse(line, &r)
+	}
+	return hp.Header()
+}
+
+// sample returns the first non-empty lines of the data
+func sample(data []byte) []string {
+	var lines []string
+	for len(data) > 0 && len(lines) < sampleLines {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			data = nil
+		}
+		line = bytes.TrimRight(line, "\r")
+		if len(line) > 0 {
+			lines = append(lines, string(line))
+		}
+	}
+	return lines
+}
+
+// regexParser parses lines with a regular expression and hands the
+// submatches to a function that fills in the record
+type regexParser struct {
+	match func(line string) []string
+	fill  func(m []string, r *query.Record)
+}
+
+func (p *regexParser) Parse(line string, r *query.Record) Result {
+	m := p.match(line)
+	if m == nil {
+		return Rejected
+	}
+	p.fill(m, r)
+	return Matched
+}
@@ -0,0 +1,108 @@
+package logformat
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/query"
+)
+
+// samples holds a line of every format, taken from the examples in the
+// format docs
+var samples = []struct {
+	format string
+	line   string
+	ip     string
+	status string
+	path   string
+}{
+	{"common", `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`, "127.0.0.1", "200", "/apache_pb.gif"},
+	{"combined", `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08"`, "127.0.0.1", "200", "/apache_pb.gif"},
+	{"nginx", `127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "POST /api HTTP/1.1" 503 0 "-" "curl/8.0" 0.012 0.010`, "127.0.0.1", "503", "/api"},
+	{"alb", `http 2018-07-02T22:23:00.186641Z app/my-loadbalancer/50dc6c495c0c9188 192.168.131.39:2817 10.0.0.1:80 0.000 0.001 0.000 200 200 34 366 "GET http://www.example.com:80/ HTTP/1.1" "curl/7.46.0" - - arn:aws:elasticloadbalancing:us-east-2:123456789012:targetgroup/my-targets/73e2d6bc24d8a067 "Root=1-58337262-36d228ad5d99923122bbe354" "-" "-" 0 2018-07-02T22:22:48.364000Z "forward" "-" "-" "10.0.0.1:80" "200" "-" "-"`, "192.168.131.39", "200", "/"},
+	{"elb", `2015-05-13T23:39:43.945958Z my-loadbalancer 192.168.131.39:2817 10.0.0.1:80 0.000073 0.001048 0.000057 404 404 0 29 "GET http://www.example.com:80/missing HTTP/1.1" "curl/7.38.0" - -`, "192.168.131.39", "404", "/missing"},
+	{"syslog", `<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - 'su root' failed for lonvick on /dev/pts/8`, "", "", ""},
+	{"json", `{"remote_addr":"10.0.0.1","time":"2000-10-10T13:55:36Z","method":"GET","path":"/","status":200,"bytes":512}`, "10.0.0.1", "200", "/"},
+	{"w3c", `2000-10-10 20:55:36 10.0.0.1 GET /default.htm - 80 - 10.0.0.2 Mozilla/5.0+(Windows) - 200 0 0 15`, "10.0.0.2", "200", "/default.htm"},
+}
+
+func TestParse(t *testing.T) {
+	for _, s := range samples {
+		f, err := Lookup(s.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r query.Record
+		if got := f.New("").Parse(s.line, &r); got != Matched {
+			t.Errorf("%s: Parse = %v, want Matched", s.format, got)
+			continue
+		}
+		for _, c := range []struct{ field, want string }{
+			{query.FieldIP, s.ip},
+			{query.FieldStatus, s.status},
+			{query.FieldPath, s.path},
+		} {
+			if got := r.Field(c.field); got != c.want {
+				t.Errorf("%s: %s = %q, want %q", s.format, c.field, got, c.want)
+			}
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	for _, s := range samples {
+		data := strings.Repeat(s.line+"\n", 10)
+		f, _, err := Detect([]byte(data))
+		if err != nil {
+			t.Errorf("%s: %v", s.format, err)
+			continue
+		}
+		if f.Name != s.format {
+			t.Errorf("detected %s for a %s sample", f.Name, s.format)
+		}
+	}
+}
+
+func TestDetectMostlyGarbage(t *testing.T) {
+	data := samples[1].line + "\nnot a log line\nnor this one\nnor that\n"
+	if f, _, err := Detect([]byte(data)); err == nil {
+		t.Errorf("detected %s when most lines do not parse", f.Name)
+	}
+}
+
+func TestW3CHeader(t *testing.T) {
+	data := "#Software: Microsoft Internet Information Services\n" +
+		"#Fields: date time c-ip cs-method cs-uri-stem sc-status\n" +
+		"2000-10-10 20:55:36 10.0.0.9 GET /custom 302\n"
+	f, header, err := Detect([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Name != "w3c" || !strings.Contains(header, "#Fields: date time c-ip") {
+		t.Fatalf("Detect = %s, header %q", f.Name, header)
+	}
+	// a later chunk without the directive is parsed with the captured header
+	var r query.Record
+	if got := f.New(header).Parse("2000-10-10 20:55:37 10.0.0.8 GET /later 500", &r); got != Matched {
+		t.Fatalf("Parse = %v, want Matched", got)
+	}
+	if r.IP != "10.0.0.8" || r.Status != "500" || r.Field(query.FieldPath) != "/later" {
+		t.Errorf("parsed %+v", r)
+	}
+	if got := f.New("").Parse("#Fields: date time", &r); got != Skipped {
+		t.Errorf("Parse of a directive = %v, want Skipped", got)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	f, err := Lookup("")
+	if err != nil || f.Name != Default {
+		t.Errorf("Lookup(\"\") = %v, %v, want %s", f, err, Default)
+	}
+	if _, err := Lookup("nope"); err == nil {
+		t.Error("Lookup of an unknown format succeeded")
+	}
+	if !f.HasField("user") || !f.HasField(query.FieldStatus) || f.HasField("nope") {
+		t.Error("HasField does not match the fields of combined")
+	}
+}
@@ -0,0 +1,53 @@
+package logformat
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/query"
+)
+
+// RFC 5424 syslog messages, ex.
+//
+//	<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - 'su root' failed for lonvick on /dev/pts/8
+var syslogRegex = regexp.MustCompile(`^<(\d{1,3})>(\d{1,2}) (\S+) (\S+) (\S+) (\S+) (\S+) (-|(?:\[(?:[^\]\\]|\\.)*\])+)(?: (.*))?$`)
+
+var syslogFormat = &Format{
+	Name:        "syslog",
+	Description: "RFC 5424 syslog",
+	Fields:      []string{"facility", "severity", "host", "app", "procid", "msgid", "structured_data", "message"},
+	New: func(string) Parser {
+		return &regexParser{match: syslogRegex.FindStringSubmatch, fill: fillSyslog}
+	},
+}
+
+// syslogSeverities are the RFC 5424 severity names, indexed by value
+var syslogSeverities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// syslogFacilities are the RFC 5424 facility names, indexed by value
+var syslogFacilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "audit", "alert", "clock",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+func fillSyslog(m []string, r *query.Record) {
+	if pri, err := strconv.Atoi(m[1]); err == nil && pri/8 < len(syslogFacilities) {
+		r.SetExtra("facility", syslogFacilities[pri/8])
+		r.SetExtra("severity", syslogSeverities[pri%8])
+	}
+	r.Time = dash(m[3])
+	r.TimeLayout = time.RFC3339Nano
+	r.SetExtra("host", dash(m[4]))
+	r.SetExtra("app", dash(m[5]))
+	r.SetExtra("procid", dash(m[6]))
+	r.SetExtra("msgid", dash(m[7]))
+	r.SetExtra("structured_data", dash(m[8]))
+	// the message may start with a UTF-8 byte order mark
+	msg := m[9]
+	if len(msg) >= 3 && msg[:3] == "\xef\xbb\xbf" {
+		msg = msg[3:]
+	}
+	r.SetExtra("message", msg)
+}
@@ -0,0 +1,137 @@
+package logformat
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/BetV3/Distributed-Multithreaded-Log-Analyzer/internal/query"
+)
+
+// W3C extended log format as written by IIS, ex.
+//
+//	#Fields: date time s-ip cs-method cs-uri-stem cs-uri-query s-port cs-username c-ip cs(User-Agent) cs(Referer) sc-status sc-substatus sc-win32-status time-taken
+//	2000-10-10 20:55:36 10.0.0.1 GET /default.htm - 80 - 10.0.0.2 Mozilla/5.0+(Windows) - 200 0 0 15
+//
+// Columns are described by the #Fields directive. Chunks that do not contain
+// it use the header captured from the first chunk, or the IIS default.
+var w3cFormat = &Format{
+	Name:        "w3c",
+	Description: "W3C extended log format (IIS)",
+	AnyField:    true,
+	New: func(header string) Parser {
+		p := &w3cParser{}
+		p.setFields(w3cDefaultFields)
+		for _, line := range strings.Split(header, "\n") {
+			p.directive(line)
+		}
+		return p
+	},
+}
+
+// w3cDefaultFields is the IIS default field list
+const w3cDefaultFields = "date time s-ip cs-method cs-uri-stem cs-uri-query s-port cs-username c-ip cs(User-Agent) cs(Referer) sc-status sc-substatus sc-win32-status time-taken"
+
+type w3cParser struct {
+	fields []string
+	// header is the #Fields directive seen last, if any
+	header string
+}
+
+func (p *w3cParser) setFields(spec string) {
+	p.fields = strings.Fields(spec)
+}
+
+// directive handles a line starting with '#' and reports whether it was one
+func (p *w3cParser) directive(line string) bool {
+	if !strings.HasPrefix(line, "#") {
+		return false
+	}
+	if spec, ok := strings.CutPrefix(line, "#Fields:"); ok {
+		p.setFields(spec)
+		p.header = line
+	}
+	return true
+}
+
+func (p *w3cParser) Header() string {
+	return p.header
+}
+
+func (p *w3cParser) Parse(line string, r *query.Record) Result {
+	if p.directive(line) {
+		return Skipped
+	}
+	values := strings.Fields(line)
+	if len(values) != len(p.fields) {
+		return Rejected
+	}
+	var date, clock, method, stem, rawQuery, version string
+	for i, name := range p.fields {
+		v := values[i]
+		if v == "-" {
+			v = ""
+		}
+		r.SetExtra(name, v)
+		switch name {
+		case "date":
+			// guards against other space separated lines that happen to
+			// have the same number of columns
+			if !isW3CDate(v) {
+				return Rejected
+			}
+			date = v
+		case "time":
+			clock = v
+		case "c-ip":
+			r.IP = v
+		case "cs-method":
+			method = v
+		case "cs-uri-stem":
+			stem = v
+		case "cs-uri-query":
+			rawQuery = v
+		case "cs-version":
+			version = v
+		case "sc-status":
+			r.Status = v
+		case "sc-bytes":
+			r.Size = v
+		case "cs(Referer)":
+			r.Referrer = v
+		case "cs(User-Agent)":
+			// IIS replaces spaces in the user agent with '+'
+			r.UserAgent = strings.ReplaceAll(v, "+", " ")
+		case "time-taken":
+			// IIS logs the time taken in milliseconds
+			if ms, err := strconv.ParseFloat(v, 64); err == nil {
+				r.RequestTime = strconv.FormatFloat(ms/1000, 'f', -1, 64)
+			}
+		}
+	}
+	// dates and times are always UTC in W3C logs
+	if date != "" && clock != "" {
+		r.Time = date + " " + clock
+		r.TimeLayout = "2006-01-02 15:04:05"
+	}
+	if method != "" {
+		path := stem
+		if rawQuery != "" {
+			path += "?" + rawQuery
+		}
+		r.Request = strings.TrimSpace(method + " " + path + " " + version)
+	}
+	return Matched
+}
+
+// isW3CDate reports whether s looks like a W3C date, ex. 2000-10-10
+func isW3CDate(s string) bool {
+	if len(s) != 10 || s[4] != '-' || s[7] != '-' {
+		return false
+	}
+	for i, c := range s {
+		if i != 4 && i != 7 && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,343 @@
+// Package metrics keeps counters, gauges and histograms and serves them in
+// the Prometheus text exposition format, without depending on the
+// Prometheus client library. A metric may have labels; each combination of
+// label values is a separate series, created the first time it is used.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are histogram bucket bounds in seconds for operations that
+// take from milliseconds to minutes
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// Registry holds the metrics of a process in the order they were created
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// metric is implemented by every kind of metric
+type metric interface {
+	write(w io.Writer) error
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes every metric in the text exposition format
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+	for _, m := range metrics {
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler serves the metrics for Prometheus to scrape
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+// desc describes a metric and holds its series, keyed by label values
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	values []string
+	// value is the count of a counter or the value of a gauge
+	value float64
+	// counts, sum and count are the state of a histogram; counts[i] is the
+	// number of observations in bucket i alone
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newDesc(name, help, kind string, labels []string) *desc {
+	d := &desc{name: name, help: help, kind: kind, labels: labels, series: make(map[string]*series)}
+	if len(labels) == 0 {
+		// a metric without labels has a single series, reported from the
+		// start
+		d.get(nil)
+	}
+	return d
+}
+
+// get returns the series of the given label values, creating it if needed.
+// The caller must hold d.mu.
+func (d *desc) get(values []string) *series {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", d.name, len(d.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := d.series[key]
+	if !ok {
+		s = &series{values: append([]string(nil), values...)}
+		d.series[key] = s
+	}
+	return s
+}
+
+// sorted returns the series ordered by label values. The caller must hold
+// d.mu.
+func (d *desc) sorted() []*series {
+	all := make([]*series, 0, len(d.series))
+	for _, s := range d.series {
+		all = append(all, s)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return strings.Join(all[i].values, "\xff") < strings.Join(all[j].values, "\xff")
+	})
+	return all
+}
+
+func (d *desc) writeHeader(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, d.kind)
+	return err
+}
+
+// writeSimple writes a counter or gauge
+func (d *desc) writeSimple(w io.Writer) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.writeHeader(w); err != nil {
+		return err
+	}
+	for _, s := range d.sorted() {
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", d.name, labelPairs(d.labels, s.values, "", ""), formatFloat(s.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Counter is a value that only goes up, such as a number of requests
+type Counter struct{ d *desc }
+
+// NewCounter creates a counter with the given label names
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{newDesc(name, help, "counter", labels)}
+	r.register(c)
+	return c
+}
+
+// Inc adds one to the series of the label values
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v, which must not be negative, to the series of the label values
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic("metrics: counter " + c.d.name + " cannot decrease")
+	}
+	c.d.mu.Lock()
+	c.d.get(values).value += v
+	c.d.mu.Unlock()
+}
+
+func (c *Counter) write(w io.Writer) error {
+	return c.d.writeSimple(w)
+}
+
+// Gauge is a value that goes up and down, such as the number of requests in
+// flight
+type Gauge struct{ d *desc }
+
+// NewGauge creates a gauge with the given label names
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{newDesc(name, help, "gauge", labels)}
+	r.register(g)
+	return g
+}
+
+// Set sets the series of the label values to v
+func (g *Gauge) Set(v float64, values ...string) {
+	g.d.mu.Lock()
+	g.d.get(values).value = v
+	g.d.mu.Unlock()
+}
+
+// Add adds v to the series of the label values
+func (g *Gauge) Add(v float64, values ...string) {
+	g.d.mu.Lock()
+	g.d.get(values).value += v
+	g.d.mu.Unlock()
+}
+
+// Delete removes the series of the label values, ex. when what it measured
+// is gone
+func (g *Gauge) Delete(values ...string) {
+	g.d.mu.Lock()
+	delete(g.d.series, strings.Join(values, "\xff"))
+	g.d.mu.Unlock()
+}
+
+func (g *Gauge) write(w io.Writer) error {
+	return g.d.writeSimple(w)
+}
+
+// gaugeFunc is a gauge without labels read when the metrics are written
+type gaugeFunc struct {
+	d  *desc
+	fn func() float64
+}
+
+// NewGaugeFunc creates a gauge whose value is fn() at the time of a scrape
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&gaugeFunc{newDesc(name, help, "gauge", nil), fn})
+}
+
+func (g *gaugeFunc) write(w io.Writer) error {
+	if err := g.d.writeHeader(w); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s %s\n", g.d.name, formatFloat(g.fn()))
+	return err
+}
+
+// Histogram counts observations, such as durations, in buckets
+type Histogram struct {
+	d *desc
+	// bounds are the upper bounds of the buckets, in increasing order
+	bounds []float64
+}
+
+// NewHistogram creates a histogram with buckets of the given upper bounds,
+// in increasing order, and label names
+func (r *Registry) NewHistogram(name, help string, bounds []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(bounds) {
+		panic("metrics: histogram " + name + " buckets are not sorted")
+	}
+	h := &Histogram{d: newDesc(name, help, "histogram", labels), bounds: bounds}
+	for _, s := range h.d.series {
+		s.counts = make([]uint64, len(bounds)+1)
+	}
+	r.register(h)
+	return h
+}
+
+// Observe adds v to the series of the label values
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.d.mu.Lock()
+	defer h.d.mu.Unlock()
+	s := h.d.get(values)
+	if s.counts == nil {
+		s.counts = make([]uint64, len(h.bounds)+1)
+	}
+	// the last count is the +Inf bucket
+	s.counts[sort.SearchFloat64s(h.bounds, v)]++
+	s.sum += v
+	s.count++
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	h.d.mu.Lock()
+	defer h.d.mu.Unlock()
+	if err := h.d.writeHeader(w); err != nil {
+		return err
+	}
+	for _, s := range h.d.sorted() {
+		var cumulative uint64
+		for i, n := range s.counts {
+			cumulative += n
+			le := math.Inf(1)
+			if i < len(h.bounds) {
+				le = h.bounds[i]
+			}
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.d.name, labelPairs(h.d.labels, s.values, "le", formatFloat(le)), cumulative); err != nil {
+				return err
+			}
+		}
+		labels := labelPairs(h.d.labels, s.values, "", "")
+		if _, err := fmt.Fprintf(w, "%s_sum%s %s\n%s_count%s %d\n", h.d.name, labels, formatFloat(s.sum), h.d.name, labels, s.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// labelPairs formats label names and values as {a="x",b="y"}, with an extra
+// pair if extraName is set, or returns "" without labels
+func labelPairs(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", name, escapeValue(values[i]))
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", extraName, escapeValue(extraValue))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	valueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeValue(s string) string { return valueEscaper.Replace(s) }
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
+
+// formatFloat formats a sample value the way Prometheus reads it
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Serve answers scrapes of /metrics on lis until it fails
+func (r *Registry) Serve(lis net.Listener) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r.Handler())
+	return http.Serve(lis, mux)
+}
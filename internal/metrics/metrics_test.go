@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"io"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	r := NewRegistry()
+	calls := r.NewCounter("calls_total", "Calls by method.\nOne line per \\ method.", "method", "code")
+	inFlight := r.NewGauge("in_flight", "Calls in flight.")
+	workers := r.NewGauge("worker_tasks", "Tasks per worker.", "worker")
+	r.NewGaugeFunc("live", "Live workers.", func() float64 { return 3 })
+	durations := r.NewHistogram("duration_seconds", "Call durations.", []float64{0.1, 1}, "method")
+
+	calls.Inc("Map", "OK")
+	calls.Add(2, "Map", "OK")
+	calls.Inc("Reduce", `say "hi"`+"\n"+`\`)
+	inFlight.Set(2)
+	inFlight.Add(-0.5)
+	workers.Set(1, "a")
+	workers.Set(4, "b")
+	workers.Delete("a")
+	for _, v := range []float64{0.05, 0.1, 0.5, 7} {
+		durations.Observe(v, "Map")
+	}
+
+	var b strings.Builder
+	if err := r.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP calls_total Calls by method.\nOne line per \\ method.
+# TYPE calls_total counter
+calls_total{method="Map",code="OK"} 3
+calls_total{method="Reduce",code="say \"hi\"\n\\"} 1
+# HELP in_flight Calls in flight.
+# TYPE in_flight gauge
+in_flight 1.5
+# HELP worker_tasks Tasks per worker.
+# TYPE worker_tasks gauge
+worker_tasks{worker="b"} 4
+# HELP live Live workers.
+# TYPE live gauge
+live 3
+# HELP duration_seconds Call durations.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{method="Map",le="0.1"} 2
+duration_seconds_bucket{method="Map",le="1"} 3
+duration_seconds_bucket{method="Map",le="+Inf"} 4
+duration_seconds_sum{method="Map"} 7.65
+duration_seconds_count{method="Map"} 4
+`
+	if b.String() != want {
+		t.Errorf("Write =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestHistogramWithoutLabels(t *testing.T) {
+	r := NewRegistry()
+	r.NewHistogram("size_bytes", "Sizes.", []float64{10})
+	var b strings.Builder
+	r.Write(&b)
+	// a histogram without labels is reported before its first observation
+	if !strings.Contains(b.String(), "size_bytes_bucket{le=\"+Inf\"} 0\nsize_bytes_sum 0\nsize_bytes_count 0\n") {
+		t.Errorf("Write =\n%s", b.String())
+	}
+}
+
+func TestMisuse(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("c", "C.", "label")
+	for name, f := range map[string]func(){
+		"decreasing counter": func() { c.Add(-1, "x") },
+		"missing label":      func() { c.Inc() },
+		"unsorted buckets":   func() { r.NewHistogram("h", "H.", []float64{2, 1}) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s did not panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	for v, want := range map[float64]string{
+		1:            "1",
+		0.25:         "0.25",
+		1e21:         "1e+21",
+		math.Inf(1):  "+Inf",
+		math.Inf(-1): "-Inf",
+	} {
+		if got := formatFloat(v); got != want {
+			t.Errorf("formatFloat(%v) = %s, want %s", v, got, want)
+		}
+	}
+	if got := formatFloat(math.NaN()); got != "NaN" {
+		t.Errorf("formatFloat(NaN) = %s", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("c_total", "C.").Inc()
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %s", ct)
+	}
+	if !strings.Contains(string(body), "\nc_total 1\n") {
+		t.Errorf("body =\n%s", body)
+	}
+}
@@ -0,0 +1,94 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	// Embed the timezone database so -timezone works on hosts without one,
+	// ex. minimal containers
+	_ "time/tzdata"
+)
+
+// BucketLayout is the layout of the bucket part of a result key
+const BucketLayout = time.RFC3339
+
+// Bucket assigns records to fixed intervals of wall clock time in a
+// timezone. Intervals are aligned to midnight, so a day bucket runs from
+// midnight to midnight in Location whatever offset the log was written with.
+type Bucket struct {
+	Size     time.Duration
+	Location *time.Location
+}
+
+// bucketNames are the sizes that can be given by name
+var bucketNames = map[string]time.Duration{
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+}
+
+// ParseBucketSize parses "minute", "hour", "day" or a duration such as "5m"
+// or "15m". The size must divide a day evenly so buckets line up with midnight.
+func ParseBucketSize(spec string) (time.Duration, error) {
+	size, ok := bucketNames[spec]
+	if !ok {
+		var err error
+		size, err = time.ParseDuration(spec)
+		if err != nil {
+			return 0, fmt.Errorf("invalid bucket size %q: want minute, hour, day or a duration such as 5m", spec)
+		}
+	}
+	if err := checkBucketSize(size); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+func checkBucketSize(size time.Duration) error {
+	if size < time.Second || size%time.Second != 0 || (24*time.Hour)%size != 0 {
+		return fmt.Errorf("invalid bucket size %v: must be whole seconds and divide a day evenly", size)
+	}
+	return nil
+}
+
+// NewBucket returns a bucket of the given size in a timezone such as "UTC",
+// "Local" or "America/New_York"
+func NewBucket(size time.Duration, timezone string) (*Bucket, error) {
+	if err := checkBucketSize(size); err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+	}
+	return &Bucket{Size: size, Location: loc}, nil
+}
+
+// Start returns the start of the bucket that t falls in
+func (b *Bucket) Start(t time.Time) time.Time {
+	t = t.In(b.Location)
+	year, month, day := t.Date()
+	// Work in wall clock seconds since midnight so days with a daylight
+	// saving change still split into the same buckets
+	secs := t.Hour()*3600 + t.Minute()*60 + t.Second()
+	size := int(b.Size / time.Second)
+	return time.Date(year, month, day, 0, 0, secs-secs%size, 0, b.Location)
+}
+
+// Key returns the start of the record's bucket in BucketLayout, or "" if the
+// record has no valid time
+func (b *Bucket) Key(r *Record) string {
+	t, err := r.Timestamp()
+	if err != nil {
+		return ""
+	}
+	return b.Start(t).Format(BucketLayout)
+}
+
+// SplitKey splits a result key produced with a bucket into the bucket and
+// the group-by key
+func SplitKey(key string) (bucket, rest string) {
+	bucket, rest, _ = strings.Cut(key, KeySeparator)
+	return bucket, rest
+}
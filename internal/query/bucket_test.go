@@ -0,0 +1,68 @@
+package query
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseBucketSize(t *testing.T) {
+	for spec, want := range map[string]time.Duration{
+		"minute": time.Minute,
+		"hour":   time.Hour,
+		"day":    24 * time.Hour,
+		"5m":     5 * time.Minute,
+		"90s":    90 * time.Second,
+	} {
+		got, err := ParseBucketSize(spec)
+		if err != nil || got != want {
+			t.Errorf("ParseBucketSize(%q) = %v, %v, want %v", spec, got, err, want)
+		}
+	}
+	for _, spec := range []string{"", "week", "7m", "500ms", "48h"} {
+		if _, err := ParseBucketSize(spec); err == nil {
+			t.Errorf("ParseBucketSize(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestBucketKey(t *testing.T) {
+	for _, c := range []struct {
+		size     time.Duration
+		timezone string
+		time     string
+		want     string
+	}{
+		{time.Hour, "UTC", "10/Oct/2000:13:55:36 -0700", "2000-10-10T20:00:00Z"},
+		{5 * time.Minute, "UTC", "10/Oct/2000:13:58:59 +0000", "2000-10-10T13:55:00Z"},
+		// a day runs from midnight to midnight in the bucket timezone
+		{24 * time.Hour, "UTC", "10/Oct/2000:23:30:00 -0700", "2000-10-11T00:00:00Z"},
+		{24 * time.Hour, "America/New_York", "11/Oct/2000:02:00:00 +0000", "2000-10-10T00:00:00-04:00"},
+		// buckets follow the wall clock on the day daylight saving starts
+		{time.Hour, "America/New_York", "02/Apr/2000:07:30:00 +0000", "2000-04-02T03:00:00-04:00"},
+		{24 * time.Hour, "America/New_York", "02/Apr/2000:07:30:00 +0000", "2000-04-02T00:00:00-05:00"},
+	} {
+		b, err := NewBucket(c.size, c.timezone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := b.Key(&Record{Time: c.time}); got != c.want {
+			t.Errorf("%v bucket in %s of %s = %s, want %s", c.size, c.timezone, c.time, got, c.want)
+		}
+	}
+}
+
+func TestBucketInvalid(t *testing.T) {
+	b, err := NewBucket(time.Hour, "UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Key(&Record{Time: "yesterday"}); got != "" {
+		t.Errorf("Key of an invalid time = %q", got)
+	}
+	if _, err := NewBucket(time.Hour, "Mars/Olympus"); err == nil {
+		t.Error("NewBucket accepted an unknown timezone")
+	}
+	if bucket, rest := SplitKey("2000-10-10T20:00:00Z|GET|/"); bucket != "2000-10-10T20:00:00Z" || rest != "GET|/" {
+		t.Errorf("SplitKey = %q, %q", bucket, rest)
+	}
+}
@@ -0,0 +1,225 @@
+// Package query defines the fields a log line is broken into and how a job
+// groups lines by those fields. It is shared by the master, which validates
+// the query before any chunk is sent, and the worker, which evaluates it.
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Field names that can be used in a group-by spec
+const (
+	FieldIP          = "ip"
+	FieldTime        = "time"
+	FieldRequest     = "request"
+	FieldMethod      = "method"
+	FieldPath        = "path"
+	FieldProtocol    = "protocol"
+	FieldStatus      = "status"
+	FieldStatusClass = "status_class"
+	FieldSize        = "size"
+	FieldReferrer    = "referrer"
+	FieldUserAgent   = "user_agent"
+	FieldRequestTime = "request_time"
+	FieldHour        = "hour"
+	FieldDay         = "day"
+	FieldFile        = "file"
+)
+
+// Fields lists every field name shared by all log formats. Formats can add
+// their own fields on top of these through Record.Extra.
+var Fields = []string{
+	FieldIP, FieldTime, FieldRequest, FieldMethod, FieldPath, FieldProtocol,
+	FieldStatus, FieldStatusClass, FieldSize, FieldReferrer, FieldUserAgent, FieldRequestTime,
+	FieldHour, FieldDay, FieldFile,
+}
+
+// DefaultGroupBy is used when a job does not name any field
+var DefaultGroupBy = GroupBy{FieldStatus}
+
+// KeySeparator joins the values of a composite key, ex. "GET|/index.html"
+const KeySeparator = "|"
+
+// TimeLayout is the layout of the time field in common and combined logs,
+// ex. 10/Oct/2000:13:55:36 -0700
+const TimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// TimeLayoutUnix marks a time field holding seconds since the epoch
+const TimeLayoutUnix = "unix"
+
+// Record holds the fields extracted from a single log line
+type Record struct {
+	IP        string
+	Time      string
+	Request   string
+	Status    string
+	Size      string
+	Referrer  string
+	UserAgent string
+	// RequestTime is the time taken to serve the request, in seconds
+	RequestTime string
+	// TimeLayout is the layout of Time; TimeLayout is used when empty
+	TimeLayout string
+	// File is the input file the line was read from
+	File string
+	// Location is the timezone hour and day are given in; UTC is used
+	// when it is nil
+	Location *time.Location
+	// Extra holds format specific fields, ex. the syslog app name
+	Extra map[string]string
+}
+
+// Field returns the value of the named field. Method, path and protocol are
+// split out of the request line; the status class (ex. 5xx) from the status;
+// hour and day are derived from the time.
+// Names that are not shared fields are looked up in Extra.
+func (r *Record) Field(name string) string {
+	switch name {
+	case FieldIP:
+		return r.IP
+	case FieldTime:
+		return r.Time
+	case FieldRequest:
+		return r.Request
+	case FieldMethod:
+		return requestPart(r.Request, 0)
+	case FieldPath:
+		return requestPart(r.Request, 1)
+	case FieldProtocol:
+		return requestPart(r.Request, 2)
+	case FieldStatus:
+		return r.Status
+	case FieldStatusClass:
+		if r.Status == "" {
+			return ""
+		}
+		return r.Status[:1] + "xx"
+	case FieldSize:
+		return r.Size
+	case FieldReferrer:
+		return r.Referrer
+	case FieldUserAgent:
+		return r.UserAgent
+	case FieldRequestTime:
+		return r.RequestTime
+	case FieldHour:
+		return r.formatTime("2006-01-02T15")
+	case FieldDay:
+		return r.formatTime("2006-01-02")
+	case FieldFile:
+		return r.File
+	}
+	return r.Extra[name]
+}
+
+// Timestamp parses the time field using the record's layout
+func (r *Record) Timestamp() (time.Time, error) {
+	switch r.TimeLayout {
+	case "":
+		return time.Parse(TimeLayout, r.Time)
+	case TimeLayoutUnix:
+		secs, err := strconv.ParseFloat(r.Time, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("parse unix time %q: %w", r.Time, err)
+		}
+		return time.Unix(0, int64(secs*float64(time.Second))).UTC(), nil
+	}
+	return time.Parse(r.TimeLayout, r.Time)
+}
+
+// formatTime reformats the time field in the record's location, or returns
+// "" if it does not parse
+func (r *Record) formatTime(layout string) string {
+	t, err := r.Timestamp()
+	if err != nil {
+		return ""
+	}
+	loc := r.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	return t.In(loc).Format(layout)
+}
+
+// SetExtra stores a format specific field
+func (r *Record) SetExtra(name, value string) {
+	if r.Extra == nil {
+		r.Extra = make(map[string]string)
+	}
+	r.Extra[name] = value
+}
+
+// requestPart returns the i-th space separated part of a request line such
+// as "GET /index.html HTTP/1.1"
+func requestPart(request string, i int) string {
+	parts := strings.SplitN(request, " ", 3)
+	if i >= len(parts) {
+		return ""
+	}
+	return parts[i]
+}
+
+// GroupBy is the list of fields whose values form a result key
+type GroupBy []string
+
+// ParseGroupBy parses a spec such as "status" or "method+path". Whether the
+// fields exist depends on the log format, see Validate.
+func ParseGroupBy(spec string) (GroupBy, error) {
+	if spec == "" {
+		return DefaultGroupBy, nil
+	}
+	g := GroupBy(strings.Split(spec, "+"))
+	for _, name := range g {
+		if name == "" {
+			return nil, fmt.Errorf("empty field in group-by %q", spec)
+		}
+	}
+	return g, nil
+}
+
+// Validate checks that every field in the group-by is either a shared field
+// or accepted by hasExtra, which reports the format specific fields.
+func (g GroupBy) Validate(hasExtra func(name string) bool) error {
+	for _, name := range g {
+		if !HasField(name, hasExtra) {
+			return fmt.Errorf("unknown group-by field %q (want one of %s or a field of the log format)", name, strings.Join(Fields, ", "))
+		}
+	}
+	return nil
+}
+
+// Key returns the result key of a record, joining the values of every
+// group-by field with KeySeparator.
+func (g GroupBy) Key(r *Record) string {
+	if len(g) == 1 {
+		return r.Field(g[0])
+	}
+	values := make([]string, len(g))
+	for i, name := range g {
+		values[i] = r.Field(name)
+	}
+	return strings.Join(values, KeySeparator)
+}
+
+// String returns the spec form of the group-by, ex. "method+path"
+func (g GroupBy) String() string {
+	return strings.Join(g, "+")
+}
+
+// HasField reports whether name is a shared field or accepted by hasExtra
+func HasField(name string, hasExtra func(name string) bool) bool {
+	return IsField(name) || (hasExtra != nil && hasExtra(name))
+}
+
+// IsField reports whether name is a known field
+func IsField(name string) bool {
+	for _, f := range Fields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
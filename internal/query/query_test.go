@@ -0,0 +1,36 @@
+package query
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHourAndDayInLocation(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// both lines were written at 2000-10-11T03:30:00Z, with different offsets
+	times := []string{"10/Oct/2000:20:30:00 -0700", "11/Oct/2000:05:30:00 +0200"}
+	for _, c := range []struct {
+		loc       *time.Location
+		hour, day string
+	}{
+		{nil, "2000-10-11T03", "2000-10-11"},
+		{time.UTC, "2000-10-11T03", "2000-10-11"},
+		{newYork, "2000-10-10T23", "2000-10-10"},
+	} {
+		for _, tm := range times {
+			r := Record{Time: tm, Location: c.loc}
+			if got := r.Field(FieldHour); got != c.hour {
+				t.Errorf("hour of %s in %v = %q, want %q", tm, c.loc, got, c.hour)
+			}
+			if got := r.Field(FieldDay); got != c.day {
+				t.Errorf("day of %s in %v = %q, want %q", tm, c.loc, got, c.day)
+			}
+		}
+	}
+	if got := (&Record{Time: "not a time"}).Field(FieldHour); got != "" {
+		t.Errorf("hour of an invalid time = %q, want \"\"", got)
+	}
+}
@@ -0,0 +1,92 @@
+// Package reservoir keeps a uniform random sample of a stream in bounded
+// memory with reservoir sampling (Vitter's algorithm R). Samples taken over
+// different parts of a stream, ex. the chunks of a log, can be merged into a
+// uniform sample of the whole stream.
+package reservoir
+
+import "math/rand/v2"
+
+// Sample holds at most size items chosen uniformly from the items seen
+type Sample[T any] struct {
+	size  int
+	seen  int64
+	items []T
+}
+
+// New returns an empty sample of at most size items
+func New[T any](size int) *Sample[T] {
+	return &Sample[T]{size: max(size, 0)}
+}
+
+// FromItems returns a sample of at most size items that was taken from seen
+// items, ex. one received from another process. Items beyond size are
+// dropped and seen is raised to the number of items if it is smaller.
+func FromItems[T any](size int, seen int64, items []T) *Sample[T] {
+	s := New[T](size)
+	if len(items) > s.size {
+		items = items[:s.size]
+	}
+	s.items = append(s.items, items...)
+	s.seen = max(seen, int64(len(items)))
+	return s
+}
+
+// Add offers an item to the sample
+func (s *Sample[T]) Add(item T) {
+	s.seen++
+	if len(s.items) < s.size {
+		s.items = append(s.items, item)
+		return
+	}
+	if i := rand.Int64N(s.seen); i < int64(s.size) {
+		s.items[i] = item
+	}
+}
+
+// Merge adds the items seen by other. The result is a uniform sample of the
+// items seen by both: each pick comes from one side with a probability
+// proportional to the items that side has left.
+func (s *Sample[T]) Merge(other *Sample[T]) {
+	a := s.items
+	b := append([]T(nil), other.items...)
+	na, nb := s.seen, max(other.seen, int64(len(other.items)))
+	n := min(s.size, len(a)+len(b))
+	merged := make([]T, 0, n)
+	for len(merged) < n {
+		var item T
+		if len(b) == 0 || (len(a) > 0 && rand.Int64N(na+nb) < na) {
+			item, a = take(a)
+			na--
+		} else {
+			item, b = take(b)
+			nb--
+		}
+		merged = append(merged, item)
+	}
+	s.items = merged
+	s.seen += max(other.seen, int64(len(other.items)))
+}
+
+// take removes a random item of items
+func take[T any](items []T) (T, []T) {
+	i := rand.IntN(len(items))
+	item := items[i]
+	last := len(items) - 1
+	items[i] = items[last]
+	return item, items[:last]
+}
+
+// Items returns the sampled items in no particular order
+func (s *Sample[T]) Items() []T {
+	return s.items
+}
+
+// Size returns the most items the sample holds
+func (s *Sample[T]) Size() int {
+	return s.size
+}
+
+// Seen returns the number of items the sample was taken from
+func (s *Sample[T]) Seen() int64 {
+	return s.seen
+}
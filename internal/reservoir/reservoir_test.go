@@ -0,0 +1,89 @@
+package reservoir
+
+import (
+	"math"
+	"testing"
+)
+
+const (
+	trials     = 20000
+	population = 100
+	sampleSize = 10
+)
+
+// checkUniform fails unless every item was picked about as often as the
+// others. The tolerance is six standard deviations so the test does not
+// flake.
+func checkUniform(t *testing.T, picks []int) {
+	t.Helper()
+	p := float64(sampleSize) / population
+	want := trials * p
+	tolerance := 6 * math.Sqrt(trials*p*(1-p))
+	for item, n := range picks {
+		if math.Abs(float64(n)-want) > tolerance {
+			t.Errorf("item %d picked %d times, want %v ± %v", item, n, want, tolerance)
+		}
+	}
+}
+
+func TestUnderCapacity(t *testing.T) {
+	s := New[int](5)
+	for i := 0; i < 3; i++ {
+		s.Add(i)
+	}
+	if len(s.Items()) != 3 || s.Seen() != 3 {
+		t.Errorf("items %v, seen %d", s.Items(), s.Seen())
+	}
+	empty := New[int](0)
+	empty.Add(1)
+	if len(empty.Items()) != 0 {
+		t.Error("a sample of size 0 kept an item")
+	}
+}
+
+func TestAddIsUniform(t *testing.T) {
+	picks := make([]int, population)
+	for trial := 0; trial < trials; trial++ {
+		s := New[int](sampleSize)
+		for i := 0; i < population; i++ {
+			s.Add(i)
+		}
+		if len(s.Items()) != sampleSize || s.Seen() != population {
+			t.Fatalf("items %v, seen %d", s.Items(), s.Seen())
+		}
+		for _, item := range s.Items() {
+			picks[item]++
+		}
+	}
+	checkUniform(t, picks)
+}
+
+func TestMergeIsUniform(t *testing.T) {
+	picks := make([]int, population)
+	for trial := 0; trial < trials; trial++ {
+		// uneven parts, the second one sent over the network
+		a, b := New[int](sampleSize), New[int](sampleSize)
+		for i := 0; i < population; i++ {
+			if i < 30 {
+				a.Add(i)
+			} else {
+				b.Add(i)
+			}
+		}
+		a.Merge(FromItems(sampleSize, b.Seen(), b.Items()))
+		if len(a.Items()) != sampleSize || a.Seen() != population {
+			t.Fatalf("items %v, seen %d", a.Items(), a.Seen())
+		}
+		for _, item := range a.Items() {
+			picks[item]++
+		}
+	}
+	checkUniform(t, picks)
+}
+
+func TestFromItems(t *testing.T) {
+	s := FromItems(2, 1, []string{"a", "b", "c"})
+	if len(s.Items()) != 2 || s.Seen() != 2 {
+		t.Errorf("items %v, seen %d, want 2 items seen 2 times", s.Items(), s.Seen())
+	}
+}
@@ -0,0 +1,72 @@
+package tlsconfig
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// reloader holds a value loaded from files and loads it again on the next
+// get after one of the files changed. If loading fails, for example while a
+// new certificate is only half written, the previous value stays in use.
+type reloader[T any] struct {
+	files []string
+	load  func() (T, error)
+	logf  func(format string, args ...any)
+
+	mu    sync.Mutex
+	value T
+	// stamp identifies the versions of the files value was loaded from, or
+	// is empty before the first load
+	stamp string
+}
+
+func newReloader[T any](logf func(string, ...any), load func() (T, error), files ...string) *reloader[T] {
+	return &reloader[T]{files: files, load: load, logf: logf}
+}
+
+// get returns the value for the current contents of the files
+func (r *reloader[T]) get() (T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stamp, err := r.fileStamp()
+	if err == nil && stamp == r.stamp {
+		return r.value, nil
+	}
+	if err == nil {
+		var value T
+		if value, err = r.load(); err == nil {
+			if r.stamp != "" && r.logf != nil {
+				r.logf("Reloaded %s", strings.Join(r.files, ", "))
+			}
+			r.value, r.stamp = value, stamp
+			return value, nil
+		}
+	}
+	if r.stamp == "" {
+		var zero T
+		return zero, err
+	}
+	if r.logf != nil {
+		r.logf("Failed to reload %s, keeping the previous version: %v", strings.Join(r.files, ", "), err)
+	}
+	if stamp != "" {
+		// load again when the files change next rather than on every get
+		r.stamp = stamp
+	}
+	return r.value, nil
+}
+
+// fileStamp returns the modification time and size of every file
+func (r *reloader[T]) fileStamp() (string, error) {
+	var b strings.Builder
+	for _, f := range r.files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", f, info.ModTime().UnixNano(), info.Size())
+	}
+	return b.String(), nil
+}
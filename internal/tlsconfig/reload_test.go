@@ -0,0 +1,87 @@
+package tlsconfig
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile replaces the contents of a file and moves its modification time
+// forward, so the change is seen even on file systems with coarse times
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	info, statErr := os.Stat(path)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if statErr == nil {
+		later := info.ModTime().Add(time.Second)
+		if err := os.Chtimes(path, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReloader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "value")
+	loads := 0
+	var logs []string
+	r := newReloader(func(format string, args ...any) {
+		logs = append(logs, format)
+	}, func() (string, error) {
+		loads++
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		if strings.HasPrefix(string(data), "bad") {
+			return "", errors.New("half written")
+		}
+		return string(data), nil
+	}, path)
+
+	if _, err := r.get(); err == nil {
+		t.Fatal("get succeeded before the file exists")
+	}
+	writeFile(t, path, "one")
+	if v, err := r.get(); err != nil || v != "one" {
+		t.Fatalf("get = %q, %v, want one", v, err)
+	}
+	r.get()
+	if loads != 1 {
+		t.Errorf("loaded %d times without a change, want once", loads)
+	}
+
+	writeFile(t, path, "two!")
+	if v, err := r.get(); err != nil || v != "two!" {
+		t.Fatalf("get after a change = %q, %v, want two!", v, err)
+	}
+
+	// a file that fails to load keeps the previous value, and is not loaded
+	// again until it changes
+	writeFile(t, path, "bad")
+	if v, err := r.get(); err != nil || v != "two!" {
+		t.Fatalf("get after a bad change = %q, %v, want two!", v, err)
+	}
+	loads = 0
+	r.get()
+	if loads != 0 {
+		t.Errorf("loaded the bad file again without a change")
+	}
+	writeFile(t, path, "three")
+	if v, err := r.get(); err != nil || v != "three" {
+		t.Fatalf("get after a fix = %q, %v, want three", v, err)
+	}
+
+	// a file that disappears keeps the previous value too
+	os.Remove(path)
+	if v, err := r.get(); err != nil || v != "three" {
+		t.Fatalf("get after removing the file = %q, %v, want three", v, err)
+	}
+	if len(logs) != 4 {
+		t.Errorf("logged %d messages, want 2 reloads and 2 failures: %v", len(logs), logs)
+	}
+}
@@ -0,0 +1,219 @@
+// Package tlsconfig builds the gRPC transport credentials of the master and
+// the workers. Both sides serve and dial, so one Config describes the
+// certificate a process presents, the CA bundle it verifies its peers with
+// and whether it requires client certificates (mutual TLS). Certificates,
+// keys and CA bundles are read again when their files change, so they can be
+// rotated without restarting the process; connections that are already open
+// keep the certificates they were set up with.
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Config names the PEM files and settings of one process. TLS is enabled
+// when CertFile is set; the zero Config uses insecure transport.
+type Config struct {
+	// CertFile and KeyFile hold the certificate presented when serving, and
+	// when dialing unless ClientCertFile is set
+	CertFile string
+	KeyFile  string
+	// ClientCertFile and ClientKeyFile hold a separate certificate presented
+	// to the servers this process dials
+	ClientCertFile string
+	ClientKeyFile  string
+	// CAFile is the CA bundle peers are verified with: the certificates of
+	// the servers this process dials, which are checked against the system
+	// roots when it is empty, and the client certificates it is sent
+	CAFile string
+	// ServerName is the name expected in the certificates of the servers
+	// this process dials, instead of the host of their address
+	ServerName string
+	// RequireClientCert rejects clients without a certificate signed by
+	// CAFile
+	RequireClientCert bool
+	// Logf, if set, reports certificates that are reloaded or that fail to
+	// reload, in which case the previous ones stay in use
+	Logf func(format string, args ...any)
+}
+
+// RegisterFlags defines the -tls-* flags of both binaries, which fill in c
+// when fs is parsed
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.CertFile, "tls-cert", "", "PEM certificate to serve with, and to present to servers for mutual TLS; turns on TLS")
+	fs.StringVar(&c.KeyFile, "tls-key", "", "PEM key of -tls-cert")
+	fs.StringVar(&c.ClientCertFile, "tls-client-cert", "", "PEM certificate to present to servers instead of -tls-cert")
+	fs.StringVar(&c.ClientKeyFile, "tls-client-key", "", "PEM key of -tls-client-cert")
+	fs.StringVar(&c.CAFile, "tls-ca", "", "PEM CA bundle that verifies server and client certificates (servers default to the system roots)")
+	fs.StringVar(&c.ServerName, "tls-server-name", "", "Name to expect in server certificates instead of the host being dialed")
+	fs.BoolVar(&c.RequireClientCert, "tls-require-client-cert", false, "Reject clients without a certificate signed by -tls-ca (mutual TLS)")
+}
+
+// Enabled reports whether the config turns on TLS
+func (c Config) Enabled() bool {
+	return c.CertFile != ""
+}
+
+// Validate checks that the settings are complete and that the files load
+func (c Config) Validate() error {
+	if !c.Enabled() {
+		if c.KeyFile != "" || c.ClientCertFile != "" || c.ClientKeyFile != "" || c.CAFile != "" || c.ServerName != "" || c.RequireClientCert {
+			return errors.New("TLS settings need a certificate and key")
+		}
+		return nil
+	}
+	if c.KeyFile == "" {
+		return errors.New("a certificate needs a key")
+	}
+	if (c.ClientCertFile == "") != (c.ClientKeyFile == "") {
+		return errors.New("a client certificate needs both a certificate and a key")
+	}
+	if c.RequireClientCert && c.CAFile == "" {
+		return errors.New("requiring client certificates needs a CA bundle to verify them")
+	}
+	if _, err := loadKeyPair(c.CertFile, c.KeyFile); err != nil {
+		return err
+	}
+	if c.ClientCertFile != "" {
+		if _, err := loadKeyPair(c.ClientCertFile, c.ClientKeyFile); err != nil {
+			return err
+		}
+	}
+	if c.CAFile != "" {
+		if _, err := loadCAs(c.CAFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Credentials returns the transport credentials of the gRPC server of the
+// process and of the clients it dials. Both read the same files.
+func (c Config) Credentials() (grpc.ServerOption, grpc.DialOption, error) {
+	if err := c.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if !c.Enabled() {
+		return grpc.Creds(insecure.NewCredentials()), grpc.WithTransportCredentials(insecure.NewCredentials()), nil
+	}
+	cert := newKeyPairReloader(c.Logf, c.CertFile, c.KeyFile)
+	clientCert := cert
+	if c.ClientCertFile != "" {
+		clientCert = newKeyPairReloader(c.Logf, c.ClientCertFile, c.ClientKeyFile)
+	}
+	var cas *reloader[*x509.CertPool]
+	if c.CAFile != "" {
+		cas = newReloader(c.Logf, func() (*x509.CertPool, error) {
+			return loadCAs(c.CAFile)
+		}, c.CAFile)
+	}
+	server := newCredentials(func() (*tls.Config, error) {
+		certificate, err := cert.get()
+		if err != nil {
+			return nil, err
+		}
+		cfg := &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{*certificate},
+		}
+		if cas != nil {
+			if cfg.ClientCAs, err = cas.get(); err != nil {
+				return nil, err
+			}
+			cfg.ClientAuth = tls.VerifyClientCertIfGiven
+			if c.RequireClientCert {
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+		}
+		return cfg, nil
+	})
+	client := newCredentials(func() (*tls.Config, error) {
+		certificate, err := clientCert.get()
+		if err != nil {
+			return nil, err
+		}
+		cfg := &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			ServerName:   c.ServerName,
+			Certificates: []tls.Certificate{*certificate},
+		}
+		if cas != nil {
+			if cfg.RootCAs, err = cas.get(); err != nil {
+				return nil, err
+			}
+		}
+		return cfg, nil
+	})
+	return grpc.Creds(server), grpc.WithTransportCredentials(client), nil
+}
+
+func newKeyPairReloader(logf func(string, ...any), certFile, keyFile string) *reloader[*tls.Certificate] {
+	return newReloader(logf, func() (*tls.Certificate, error) {
+		return loadKeyPair(certFile, keyFile)
+	}, certFile, keyFile)
+}
+
+// reloadingCredentials performs every handshake with a TLS config built
+// from the current files
+type reloadingCredentials struct {
+	credentials.TransportCredentials
+	config func() (*tls.Config, error)
+}
+
+func newCredentials(config func() (*tls.Config, error)) credentials.TransportCredentials {
+	// the embedded credentials only describe the protocol in Info
+	return &reloadingCredentials{TransportCredentials: credentials.NewTLS(&tls.Config{}), config: config}
+}
+
+func (r *reloadingCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	cfg, err := r.config()
+	if err != nil {
+		return nil, nil, err
+	}
+	return credentials.NewTLS(cfg).ClientHandshake(ctx, authority, conn)
+}
+
+func (r *reloadingCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	cfg, err := r.config()
+	if err != nil {
+		return nil, nil, err
+	}
+	return credentials.NewTLS(cfg).ServerHandshake(conn)
+}
+
+func (r *reloadingCredentials) Clone() credentials.TransportCredentials {
+	return newCredentials(r.config)
+}
+
+// loadKeyPair reads a certificate and its key
+func loadKeyPair(certFile, keyFile string) (*tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load certificate %s: %w", certFile, err)
+	}
+	return &cert, nil
+}
+
+// loadCAs reads a bundle of PEM CA certificates
+func loadCAs(caFile string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("load CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("load CA bundle %s: no PEM certificates found", caFile)
+	}
+	return pool, nil
+}
@@ -0,0 +1,93 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed certificate and its key for name and
+// returns the paths of both files
+func writeCert(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeFile(t, certFile, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	writeFile(t, keyFile, string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})))
+	return certFile, keyFile
+}
+
+func TestValidate(t *testing.T) {
+	certFile, keyFile := writeCert(t, t.TempDir(), "worker")
+	for _, c := range []struct {
+		name   string
+		config Config
+		ok     bool
+	}{
+		{"insecure", Config{}, true},
+		{"settings without a certificate", Config{CAFile: certFile}, false},
+		{"certificate without a key", Config{CertFile: certFile}, false},
+		{"certificate", Config{CertFile: certFile, KeyFile: keyFile}, true},
+		{"half a client certificate", Config{CertFile: certFile, KeyFile: keyFile, ClientCertFile: certFile}, false},
+		{"client certificates without a CA", Config{CertFile: certFile, KeyFile: keyFile, RequireClientCert: true}, false},
+		{"mutual TLS", Config{CertFile: certFile, KeyFile: keyFile, CAFile: certFile, RequireClientCert: true}, true},
+		{"key as CA bundle", Config{CertFile: certFile, KeyFile: keyFile, CAFile: keyFile}, false},
+		{"missing file", Config{CertFile: certFile, KeyFile: keyFile + ".missing"}, false},
+	} {
+		if err := c.config.Validate(); (err == nil) != c.ok {
+			t.Errorf("%s: Validate = %v", c.name, err)
+		}
+	}
+}
+
+func TestKeyPairReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "first")
+	r := newKeyPairReloader(nil, certFile, keyFile)
+	cert, err := r.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Leaf.Subject.CommonName != "first" {
+		t.Fatalf("loaded %s", cert.Leaf.Subject.CommonName)
+	}
+	// a rotated certificate is used by the next handshake
+	writeCert(t, dir, "second")
+	if cert, err = r.get(); err != nil || cert.Leaf.Subject.CommonName != "second" {
+		t.Fatalf("after rotation got %v, %v, want second", cert.Leaf.Subject.CommonName, err)
+	}
+	// a certificate that no longer matches its key keeps the previous pair
+	writeFile(t, keyFile, "not a key")
+	if cert, err = r.get(); err != nil || cert.Leaf.Subject.CommonName != "second" {
+		t.Fatalf("after a bad key got %v, %v, want second", cert, err)
+	}
+}
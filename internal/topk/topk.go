@@ -0,0 +1,188 @@
+// Package topk finds the most frequent keys of a stream in bounded memory
+// with the Space-Saving algorithm (Metwally, Agrawal and El Abbadi). Sketches
+// built over different parts of a stream, ex. the chunks of a log, can be
+// merged into a sketch of the whole stream.
+package topk
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// Counter is the estimate for one key: the key occurred at most Count times
+// and at least Count-Error times.
+type Counter struct {
+	Key   string
+	Count int64
+	Error int64
+}
+
+// Sketch keeps at most capacity counters. Once it is full, a new key takes
+// over the counter with the smallest count, inheriting that count as its
+// error. Every count overestimates by at most the total number of items
+// divided by the capacity.
+type Sketch struct {
+	capacity int
+	counters counterHeap
+	index    map[string]*counter
+}
+
+// counter is a Counter with its position in the heap
+type counter struct {
+	Counter
+	pos int
+}
+
+// New returns an empty sketch with the given number of counters
+func New(capacity int) *Sketch {
+	return &Sketch{
+		capacity: max(capacity, 1),
+		index:    make(map[string]*counter),
+	}
+}
+
+// FromCounters rebuilds a sketch from the counters of another one, ex. after
+// they were sent over the network. Only the capacity largest counters are kept.
+func FromCounters(capacity int, counters []Counter) *Sketch {
+	s := New(capacity)
+	s.reset(append([]Counter(nil), counters...))
+	return s
+}
+
+// Capacity returns the maximum number of counters
+func (s *Sketch) Capacity() int {
+	return s.capacity
+}
+
+// Len returns the number of counters in use
+func (s *Sketch) Len() int {
+	return len(s.counters)
+}
+
+// Add counts n occurrences of key
+func (s *Sketch) Add(key string, n int64) {
+	if c, ok := s.index[key]; ok {
+		c.Count += n
+		heap.Fix(&s.counters, c.pos)
+		return
+	}
+	if len(s.counters) < s.capacity {
+		c := &counter{Counter: Counter{Key: key, Count: n}}
+		s.index[key] = c
+		heap.Push(&s.counters, c)
+		return
+	}
+	// Replace the smallest counter
+	c := s.counters[0]
+	delete(s.index, c.Key)
+	c.Key, c.Error, c.Count = key, c.Count, c.Count+n
+	s.index[key] = c
+	heap.Fix(&s.counters, 0)
+}
+
+// Floor returns the most a key without a counter may have occurred: the
+// smallest count once the sketch is full, zero before.
+func (s *Sketch) Floor() int64 {
+	if len(s.counters) < s.capacity {
+		return 0
+	}
+	return s.counters[0].Count
+}
+
+// Merge adds the counts of other into s. A key missing from one of the
+// sketches is assumed to have occurred as often as that sketch's floor, which
+// keeps every count an overestimate and widens its error to match.
+func (s *Sketch) Merge(other *Sketch) {
+	floor, otherFloor := s.Floor(), other.Floor()
+	merged := make(map[string]*Counter, len(s.counters)+len(other.counters))
+	for _, c := range s.counters {
+		merged[c.Key] = &Counter{Key: c.Key, Count: c.Count + otherFloor, Error: c.Error + otherFloor}
+	}
+	for _, c := range other.counters {
+		if m, ok := merged[c.Key]; ok {
+			m.Count += c.Count - otherFloor
+			m.Error += c.Error - otherFloor
+			continue
+		}
+		merged[c.Key] = &Counter{Key: c.Key, Count: c.Count + floor, Error: c.Error + floor}
+	}
+	counters := make([]Counter, 0, len(merged))
+	for _, c := range merged {
+		counters = append(counters, *c)
+	}
+	s.reset(counters)
+}
+
+// reset replaces the counters of s with the capacity largest of counters
+func (s *Sketch) reset(counters []Counter) {
+	sortCounters(counters)
+	if len(counters) > s.capacity {
+		counters = counters[:s.capacity]
+	}
+	s.counters = make(counterHeap, len(counters))
+	s.index = make(map[string]*counter, len(counters))
+	for i, c := range counters {
+		s.counters[i] = &counter{Counter: c, pos: i}
+		s.index[c.Key] = s.counters[i]
+	}
+	heap.Init(&s.counters)
+}
+
+// Counters returns every counter, largest count first
+func (s *Sketch) Counters() []Counter {
+	counters := make([]Counter, len(s.counters))
+	for i, c := range s.counters {
+		counters[i] = c.Counter
+	}
+	sortCounters(counters)
+	return counters
+}
+
+// Top returns the n counters with the largest counts
+func (s *Sketch) Top(n int) []Counter {
+	return Top(s.Counters(), n)
+}
+
+// Top sorts counters by count, largest first with ties broken by key, and
+// returns the first n
+func Top(counters []Counter, n int) []Counter {
+	sortCounters(counters)
+	if n < len(counters) {
+		counters = counters[:n]
+	}
+	return counters
+}
+
+func sortCounters(counters []Counter) {
+	sort.Slice(counters, func(i, j int) bool {
+		if counters[i].Count != counters[j].Count {
+			return counters[i].Count > counters[j].Count
+		}
+		return counters[i].Key < counters[j].Key
+	})
+}
+
+// counterHeap is a min-heap of counters ordered by count
+type counterHeap []*counter
+
+func (h counterHeap) Len() int           { return len(h) }
+func (h counterHeap) Less(i, j int) bool { return h[i].Count < h[j].Count }
+
+func (h counterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].pos = i
+	h[j].pos = j
+}
+
+func (h *counterHeap) Push(x any) {
+	c := x.(*counter)
+	c.pos = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *counterHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
@@ -0,0 +1,100 @@
+package topk
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// zipfStream returns a skewed stream of keys and the true count of each
+func zipfStream(n int, seed int64) ([]string, map[string]int64) {
+	r := rand.New(rand.NewSource(seed))
+	z := rand.NewZipf(r, 1.2, 1, 10000)
+	keys := make([]string, n)
+	counts := make(map[string]int64)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", z.Uint64())
+		counts[keys[i]]++
+	}
+	return keys, counts
+}
+
+// checkBounds fails unless every counter brackets the true count of its key
+func checkBounds(t *testing.T, s *Sketch, counts map[string]int64) {
+	t.Helper()
+	for _, c := range s.Counters() {
+		if got := counts[c.Key]; got > c.Count || got < c.Count-c.Error {
+			t.Errorf("%s occurred %d times, outside [%d, %d]", c.Key, got, c.Count-c.Error, c.Count)
+		}
+	}
+}
+
+func TestExactUnderCapacity(t *testing.T) {
+	s := New(10)
+	for i := 0; i < 5; i++ {
+		for j := 0; j <= i; j++ {
+			s.Add(fmt.Sprint(i), 1)
+		}
+	}
+	top := s.Top(2)
+	if len(top) != 2 || top[0] != (Counter{Key: "4", Count: 5}) || top[1] != (Counter{Key: "3", Count: 4}) {
+		t.Errorf("Top(2) = %v", top)
+	}
+	if s.Floor() != 0 {
+		t.Errorf("Floor() = %d before the sketch is full", s.Floor())
+	}
+}
+
+func TestBounds(t *testing.T) {
+	keys, counts := zipfStream(100000, 1)
+	s := New(100)
+	for _, k := range keys {
+		s.Add(k, 1)
+	}
+	if s.Len() != 100 {
+		t.Fatalf("Len() = %d, want 100", s.Len())
+	}
+	checkBounds(t, s, counts)
+	// the most frequent key of a skewed stream is found exactly
+	if top := s.Top(1)[0]; top.Key != "key-0" || top.Error != 0 {
+		t.Errorf("Top(1) = %v, want key-0 without error", top)
+	}
+	// keys without a counter occurred at most Floor() times
+	for k, n := range counts {
+		if _, ok := s.index[k]; !ok && n > s.Floor() {
+			t.Errorf("%s occurred %d times without a counter, floor %d", k, n, s.Floor())
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	keys, counts := zipfStream(100000, 2)
+	a, b := New(100), New(100)
+	for i, k := range keys {
+		if i%2 == 0 {
+			a.Add(k, 1)
+		} else {
+			b.Add(k, 1)
+		}
+	}
+	a.Merge(b)
+	if a.Len() > 100 {
+		t.Fatalf("merged sketch has %d counters", a.Len())
+	}
+	checkBounds(t, a, counts)
+	if top := a.Top(1)[0]; top.Key != "key-0" {
+		t.Errorf("Top(1) after merge = %v, want key-0", top)
+	}
+}
+
+func TestFromCounters(t *testing.T) {
+	s := FromCounters(2, []Counter{{Key: "a", Count: 1}, {Key: "b", Count: 3}, {Key: "c", Count: 2}})
+	got := s.Counters()
+	if len(got) != 2 || got[0].Key != "b" || got[1].Key != "c" {
+		t.Errorf("Counters() = %v, want b and c", got)
+	}
+	// ties are broken by key
+	if top := Top([]Counter{{Key: "z", Count: 1}, {Key: "y", Count: 1}}, 1); top[0].Key != "y" {
+		t.Errorf("Top = %v, want y", top)
+	}
+}